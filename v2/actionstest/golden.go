@@ -0,0 +1,79 @@
+package actionstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2"
+)
+
+// GoldenDir is the directory golden files are kept in, relative to the package under test.
+const GoldenDir = "testdata"
+
+// update is namespaced so it does not collide with an -update flag of the test using
+// the package.
+var update = flag.Bool("actionstest.update", false, "rewrite golden files with the encoded responses")
+
+// Golden encodes enc and compares it with the golden file testdata/<name>.json, failing t
+// with a line diff if they differ. JSON object keys are sorted and indented before
+// comparison, so golden files do not depend on field order. Run the test with
+// -actionstest.update to write the golden files instead.
+func Golden(t testing.TB, name string, enc v2.Encoder) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := enc.Encode(buf); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got, err := normalize(buf.Bytes())
+	if err != nil {
+		t.Fatalf("%s: %v\n%s", name, err, buf)
+	}
+
+	path := filepath.Join(GoldenDir, name+".json")
+	if *update {
+		if err := os.MkdirAll(GoldenDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -actionstest.update to create it)", err)
+	}
+	want, err := normalize(data)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	if d := diff(lines(want), lines(got)); d != "" {
+		t.Errorf("%s (-want +got):\n%s", path, d)
+	}
+}
+
+// normalize returns data indented with its object keys sorted. Numbers are kept as
+// written, so large integers are not rounded to float64.
+func normalize(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func lines(data []byte) []string {
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
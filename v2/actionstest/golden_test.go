@@ -0,0 +1,33 @@
+package actionstest
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	a, err := normalize([]byte(`{"b":1,"a":{"d":[1,2],"c":"x"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := normalize([]byte(`{"a": {"c": "x", "d": [1, 2]}, "b": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Errorf("want: %s, got: %s", a, b)
+	}
+}
+
+func TestNormalizeLargeIntegers(t *testing.T) {
+	a, err := normalize([]byte(`{"id":9007199254740993}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := normalize([]byte(`{"id":9007199254740992}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) == string(b) {
+		t.Errorf("want integers above 2^53 kept distinct, got: %s", a)
+	}
+}
@@ -0,0 +1,400 @@
+// Package actionstest builds fulfillment requests, checks encoded responses and plays
+// multi-turn conversations for testing Actions.
+package actionstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/google"
+)
+
+const (
+	// DefaultProject is the Dialogflow project of built sessions.
+	DefaultProject = "actionstest"
+
+	// DefaultLocale is the locale of built requests.
+	DefaultLocale = "en-US"
+
+	// WelcomeIntent is the display name of Dialogflow's default welcome intent.
+	WelcomeIntent = "Default Welcome Intent"
+
+	// FallbackIntent is the display name of Dialogflow's default fallback intent.
+	FallbackIntent = "Default Fallback Intent"
+)
+
+// Surface capabilities.
+const (
+	ScreenOutput       = "actions.capability.SCREEN_OUTPUT"
+	AudioOutput        = "actions.capability.AUDIO_OUTPUT"
+	MediaResponseAudio = "actions.capability.MEDIA_RESPONSE_AUDIO"
+	WebBrowser         = "actions.capability.WEB_BROWSER"
+)
+
+// Actions intents.
+const (
+	MainIntent        = "actions.intent.MAIN"
+	TextIntent        = "actions.intent.TEXT"
+	OptionIntent      = "actions.intent.OPTION"
+	SignInIntent      = "actions.intent.SIGN_IN"
+	PermissionIntent  = "actions.intent.PERMISSION"
+	NoInputIntent     = "actions.intent.NO_INPUT"
+	MediaStatusIntent = "actions.intent.MEDIA_STATUS"
+	CancelIntent      = "actions.intent.CANCEL"
+)
+
+// DefaultCapabilities are the capabilities of a phone.
+var DefaultCapabilities = []string{ScreenOutput, AudioOutput, MediaResponseAudio, WebBrowser}
+
+var sessions int64
+
+// Builder builds a dialogflow.Request. Its methods return the Builder so calls chain.
+type Builder struct {
+	req *dialogflow.Request
+}
+
+// NewRequest returns a Builder of a text query in a new session, from a user on a
+// phone in DefaultLocale.
+func NewRequest() *Builder {
+	n := atomic.AddInt64(&sessions, 1)
+	req := &dialogflow.Request{
+		ResponseID: fmt.Sprintf("actionstest-response-%d", n),
+		Session:    fmt.Sprintf("projects/%s/agent/sessions/%d", DefaultProject, n),
+	}
+	req.QueryResult.LanguageCode = DefaultLocale
+	req.QueryResult.AllRequiredParamsPresent = true
+	req.QueryResult.IntentDetectionConfidence = 1
+	req.QueryResult.RawParameterData = map[string]json.RawMessage{}
+	req.OriginalDetectIntentRequest.Source = "google"
+	req.OriginalDetectIntentRequest.Version = "2"
+	req.OriginalDetectIntentRequest.Payload = &google.Request{
+		User: &google.User{
+			UserID: fmt.Sprintf("actionstest-user-%d", n),
+			Locale: DefaultLocale,
+		},
+		Conversation: &google.Conversation{
+			ConversationID: fmt.Sprint(n),
+			Type:           google.ActiveConversation,
+		},
+		Surface: &google.Surface{},
+		Inputs: []*google.Input{
+			{Intent: TextIntent},
+		},
+	}
+	return (&Builder{req}).Capabilities(DefaultCapabilities...)
+}
+
+// Welcome builds the first request of a conversation, invoking the Action.
+func Welcome() *Builder {
+	return NewRequest().
+		Intent(WelcomeIntent).
+		ActionsIntent(MainIntent).
+		ConversationType(google.NewConversation).
+		Query("GOOGLE_ASSISTANT_WELCOME")
+}
+
+// TextQuery builds a request of the user saying text.
+func TextQuery(text string) *Builder {
+	return NewRequest().Query(text)
+}
+
+// OptionSelected builds the request sent when the user selects the list or carousel
+// item with key.
+func OptionSelected(key string) *Builder {
+	return event(OptionIntent).Argument(&google.Argument{
+		Name:      google.OptionArgument,
+		TextValue: key,
+		Extension: extension(google.OptionValueType, &google.OptionValue{Key: key}),
+	})
+}
+
+// SignInResult builds the request sent after a sign-in helper with status.
+func SignInResult(status google.SignInStatus) *Builder {
+	return event(SignInIntent).Argument(&google.Argument{
+		Name:      google.SignInArgument,
+		Extension: extension(google.SignInValueType, &google.SignInValue{Status: status}),
+	})
+}
+
+// PermissionGranted builds the request sent after a permission helper the user
+// granted or refused, with the user's name as the name permission would provide.
+func PermissionGranted(granted bool) *Builder {
+	b := event(PermissionIntent).Argument(&google.Argument{
+		Name:      google.PermissionArgument,
+		TextValue: fmt.Sprint(granted),
+		BoolValue: granted,
+		Extension: extension(google.PermissionValueType, &google.PermissionValue{PermissionGranted: granted}),
+	})
+	if granted {
+		b.req.OriginalDetectIntentRequest.Payload.User.Profile = &google.UserProfile{
+			DisplayName: "Sam Jones",
+			GivenName:   "Sam",
+			FamilyName:  "Jones",
+		}
+	}
+	return b
+}
+
+// NoInput builds the request sent when the user said nothing, the repromptCount-th time.
+func NoInput(repromptCount int) *Builder {
+	return event(NoInputIntent).
+		Argument(&google.Argument{Name: "REPROMPT_COUNT", IntValue: fmt.Sprint(repromptCount)}).
+		Argument(&google.Argument{Name: "IS_FINAL_REPROMPT", BoolValue: repromptCount >= 2})
+}
+
+// MediaStatus builds the request sent when media playback reports status.
+func MediaStatus(status google.PlaybackStatus) *Builder {
+	return event(MediaStatusIntent).Argument(&google.Argument{
+		Name:      google.MediaStatusArgument,
+		Extension: extension(google.MediaStatusType, &google.MediaStatus{Status: status}),
+	})
+}
+
+// Cancel builds the request sent when the user exits the conversation.
+func Cancel() *Builder {
+	b := event(CancelIntent)
+	b.input().RawInputs = []*google.RawInput{
+		{InputType: google.VoiceInput, Query: "cancel"},
+	}
+	return b
+}
+
+// event builds the request of an Actions intent, which Dialogflow reports as the query
+// text actions_intent_<NAME>.
+func event(intent string) *Builder {
+	b := NewRequest().ActionsIntent(intent)
+	b.req.QueryResult.QueryText = "actions_intent_" + intent[len("actions.intent."):]
+	return b
+}
+
+func extension(typ string, v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	m := map[string]interface{}{}
+	json.Unmarshal(data, &m)
+	m["@type"] = typ
+	data, err = json.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (b *Builder) payload() *google.Request {
+	return b.req.OriginalDetectIntentRequest.Payload
+}
+
+func (b *Builder) input() *google.Input {
+	return b.payload().Inputs[0]
+}
+
+// Intent sets the matched Dialogflow intent's display name.
+func (b *Builder) Intent(displayName string) *Builder {
+	b.req.QueryResult.Intent.DisplayName = displayName
+	b.req.QueryResult.Intent.Name = fmt.Sprintf("projects/%s/agent/intents/%s", DefaultProject, displayName)
+	return b
+}
+
+// Action sets the matched intent's action.
+func (b *Builder) Action(action string) *Builder {
+	b.req.QueryResult.Action = action
+	return b
+}
+
+// Query sets what the user said or typed.
+func (b *Builder) Query(text string) *Builder {
+	b.req.QueryResult.QueryText = text
+	in := b.input()
+	in.RawInputs = []*google.RawInput{
+		{InputType: google.KeyboardInput, Query: text},
+	}
+	if in.Intent == TextIntent || in.Intent == MainIntent {
+		b.setArgument(&google.Argument{Name: "text", RawText: text, TextValue: text})
+	}
+	return b
+}
+
+// Voice marks the query as spoken rather than typed.
+func (b *Builder) Voice() *Builder {
+	for _, raw := range b.input().RawInputs {
+		raw.InputType = google.VoiceInput
+	}
+	return b
+}
+
+// ActionsIntent sets the Actions intent of the input.
+func (b *Builder) ActionsIntent(intent string) *Builder {
+	b.input().Intent = intent
+	return b
+}
+
+// Argument adds or replaces an argument of the input.
+func (b *Builder) Argument(a *google.Argument) *Builder {
+	b.setArgument(a)
+	return b
+}
+
+func (b *Builder) setArgument(a *google.Argument) {
+	in := b.input()
+	for i, arg := range in.Arguments {
+		if arg.Name == a.Name {
+			in.Arguments[i] = a
+			return
+		}
+	}
+	in.Arguments = append(in.Arguments, a)
+}
+
+// Parameter sets a Dialogflow parameter to value, encoded as JSON.
+func (b *Builder) Parameter(name string, value interface{}) *Builder {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	b.req.QueryResult.RawParameterData[name] = data
+	return b
+}
+
+// OutputContext adds an output context by its short name.
+func (b *Builder) OutputContext(name string) *Builder {
+	b.req.QueryResult.OutputContexts = append(b.req.QueryResult.OutputContexts, dialogflow.Context{
+		Name: b.req.Session + "/contexts/" + name,
+	})
+	return b
+}
+
+// ContextParameter sets a parameter of the output context with the short name context to
+// value, encoded as JSON, adding the context if needed.
+func (b *Builder) ContextParameter(context, name string, value interface{}) *Builder {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	c := b.context(context)
+	if c == nil {
+		b.OutputContext(context)
+		c = b.context(context)
+	}
+	if c.Parameters == nil {
+		c.Parameters = map[string]json.RawMessage{}
+	}
+	c.Parameters[name] = data
+	return b
+}
+
+// context returns the output context with the short name, or nil.
+func (b *Builder) context(name string) *dialogflow.Context {
+	contexts := b.req.QueryResult.OutputContexts
+	for i := range contexts {
+		if shortName(contexts[i].Name) == name {
+			return &contexts[i]
+		}
+	}
+	return nil
+}
+
+// shortName returns the last segment of a context's full name.
+func shortName(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// Locale sets the user's locale and the query's language.
+func (b *Builder) Locale(locale string) *Builder {
+	b.req.QueryResult.LanguageCode = locale
+	b.payload().User.Locale = locale
+	return b
+}
+
+// Capabilities sets the surface capabilities, e.g. only AudioOutput for a smart speaker.
+func (b *Builder) Capabilities(names ...string) *Builder {
+	s := b.payload().Surface
+	s.Capabilities = s.Capabilities[:0]
+	for _, name := range names {
+		s.Capabilities = append(s.Capabilities, google.Capability{Name: name})
+	}
+	return b
+}
+
+// UserStorage sets the storage the Action saved for the user.
+func (b *Builder) UserStorage(storage string) *Builder {
+	b.payload().User.UserStorage = storage
+	return b
+}
+
+// User sets the user's ID.
+func (b *Builder) User(id string) *Builder {
+	b.payload().User.UserID = id
+	return b
+}
+
+// AccessToken sets the token of the user's linked account.
+func (b *Builder) AccessToken(token string) *Builder {
+	b.payload().User.AccessToken = token
+	return b
+}
+
+// IDToken sets the user's Google Sign-In ID token.
+func (b *Builder) IDToken(token string) *Builder {
+	b.payload().User.IDToken = token
+	return b
+}
+
+// Session sets the Dialogflow session.
+func (b *Builder) Session(session string) *Builder {
+	b.req.Session = session
+	return b
+}
+
+// ResponseID sets the request's response ID.
+func (b *Builder) ResponseID(id string) *Builder {
+	b.req.ResponseID = id
+	return b
+}
+
+// Conversation sets the conversation's ID and token.
+func (b *Builder) Conversation(id, token string) *Builder {
+	c := b.payload().Conversation
+	c.ConversationID = id
+	c.ConversationToken = token
+	return b
+}
+
+// ConversationType sets whether the conversation is new or active.
+func (b *Builder) ConversationType(t google.ConversationType) *Builder {
+	b.payload().Conversation.Type = t
+	return b
+}
+
+// JSON returns the request as Dialogflow sends it: camelCase keys, with unset fields
+// omitted.
+func (b *Builder) JSON() []byte {
+	data, err := json.Marshal(b.req)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// Build returns the request as a handler receives it, decoded from JSON.
+func (b *Builder) Build() *dialogflow.Request {
+	req, err := dialogflow.Decode(bytes.NewReader(b.JSON()))
+	if err != nil {
+		panic(err)
+	}
+	return req
+}
+
+// HTTPRequest returns the request as Dialogflow posts it to the fulfillment webhook.
+func (b *Builder) HTTPRequest() *http.Request {
+	r := httptest.NewRequest("POST", "/", bytes.NewReader(b.JSON()))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
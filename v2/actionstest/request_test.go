@@ -0,0 +1,184 @@
+package actionstest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/fulfillment"
+	"github.com/damondouglas/go.actions/v2/google"
+)
+
+func TestWelcome(t *testing.T) {
+	req := Welcome().Locale("fr-FR").Capabilities(AudioOutput).Build()
+	payload := req.OriginalDetectIntentRequest.Payload
+	if got := payload.Conversation.Type; got != google.NewConversation {
+		t.Errorf("want: %v, got: %v", google.NewConversation, got)
+	}
+	if got := payload.Inputs[0].Intent; got != MainIntent {
+		t.Errorf("want: %v, got: %v", MainIntent, got)
+	}
+	if got := req.QueryResult.Intent.DisplayName; got != WelcomeIntent {
+		t.Errorf("want: %v, got: %v", WelcomeIntent, got)
+	}
+	if got := payload.User.Locale; got != "fr-FR" {
+		t.Errorf("want: fr-FR, got: %v", got)
+	}
+	if got := len(payload.Surface.Capabilities); got != 1 {
+		t.Errorf("want: 1, got: %v", got)
+	}
+}
+
+func TestTextQuery(t *testing.T) {
+	req := TextQuery("tell me a joke").
+		Intent("joke").
+		Parameter("topic", "cats").
+		UserStorage(`{"jokes":3}`).
+		Build()
+	if got := req.QueryResult.QueryText; got != "tell me a joke" {
+		t.Errorf("want: tell me a joke, got: %v", got)
+	}
+	if got := req.QueryResult.Parameters["topic"].String(); got != "cats" {
+		t.Errorf("want: cats, got: %v", got)
+	}
+	if got := req.OriginalDetectIntentRequest.Payload.User.UserStorage; got != `{"jokes":3}` {
+		t.Errorf("want: %v, got: %v", `{"jokes":3}`, got)
+	}
+	if a, b := NewRequest().Build(), NewRequest().Build(); a.Session == b.Session {
+		t.Errorf("want distinct sessions, got: %v", a.Session)
+	}
+}
+
+func TestHelperResults(t *testing.T) {
+	in := func(b *Builder) *google.Input {
+		return b.Build().OriginalDetectIntentRequest.Payload.Inputs[0]
+	}
+
+	option, err := in(OptionSelected("first")).OptionValue()
+	if err != nil || option.Key != "first" {
+		t.Errorf("want: first, got: %v %v", option, err)
+	}
+	signin, err := in(SignInResult(google.SignInOK)).SignInValue()
+	if err != nil || signin.Status != google.SignInOK {
+		t.Errorf("want: %v, got: %v %v", google.SignInOK, signin, err)
+	}
+	permission, err := in(PermissionGranted(true)).PermissionValue()
+	if err != nil || !permission.PermissionGranted {
+		t.Errorf("want: true, got: %v %v", permission, err)
+	}
+	media, err := in(MediaStatus(google.MediaFinished)).MediaStatus()
+	if err != nil || media.Status != google.MediaFinished {
+		t.Errorf("want: %v, got: %v %v", google.MediaFinished, media, err)
+	}
+	if got := in(NoInput(2)).Argument("IS_FINAL_REPROMPT"); got == nil || !got.BoolValue {
+		t.Errorf("want final reprompt, got: %v", got)
+	}
+	if got := Cancel().Build().QueryResult.QueryText; got != "actions_intent_CANCEL" {
+		t.Errorf("want: actions_intent_CANCEL, got: %v", got)
+	}
+}
+
+func TestHTTPRequest(t *testing.T) {
+	r := fulfillment.NewRouter()
+	for _, name := range []string{MainIntent, OptionIntent, CancelIntent} {
+		name := name
+		r.ActionsIntent(name, fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+			return &v2.Simple{Say: name}, nil
+		}))
+	}
+
+	for _, b := range []*Builder{Welcome(), OptionSelected("first"), Cancel()} {
+		want := b.Build().OriginalDetectIntentRequest.Payload.Inputs[0].Intent
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, b.HTTPRequest())
+		if w.Code != 200 {
+			t.Fatalf("%s: want: 200, got: %v %s", want, w.Code, w.Body)
+		}
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("want: %v, got: %s", want, w.Body)
+		}
+	}
+}
+
+// unmodeled are keys of the mock request the Builder has no method for.
+var unmodeled = map[string]bool{
+	"originalDetectIntentRequest.payload.isInSandbox":                             true,
+	"originalDetectIntentRequest.payload.user.lastSeen":                           true,
+	"originalDetectIntentRequest.payload.availableSurfaces":                       true,
+	"originalDetectIntentRequest.payload.availableSurfaces[].capabilities":        true,
+	"originalDetectIntentRequest.payload.availableSurfaces[].capabilities[].name": true,
+}
+
+func TestJSONKeys(t *testing.T) {
+	data, err := ioutil.ReadFile("../mock/v2/request.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := keys(t, data)
+	got := keys(t, TextQuery("query from the user").
+		Intent("Name of Dialogflow Intent").
+		Action("action.name.of.matched.dialogflow.intent").
+		OutputContext("actions_capability_screen_output").
+		Conversation("1522951193000", "[]").
+		JSON())
+
+	for k := range got {
+		if !want[k] {
+			t.Errorf("want no key %s, not in the mock request", k)
+		}
+	}
+	var missing []string
+	for k := range want {
+		if !got[k] && !unmodeled[k] {
+			missing = append(missing, k)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("want keys: %v", missing)
+	}
+}
+
+func TestJSONOmitsUnset(t *testing.T) {
+	data := string(TextQuery("hello").JSON())
+	for _, unset := range []string{"datetimeValue", "idToken", "accessToken", "profile", "device", "null"} {
+		if strings.Contains(data, unset) {
+			t.Errorf("want no %s, got: %s", unset, data)
+		}
+	}
+}
+
+// keys returns the paths of the object keys of JSON data, with [] for array elements.
+func keys(t *testing.T, data []byte) map[string]bool {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]bool{}
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, e := range v {
+				path := k
+				if prefix != "" {
+					path = prefix + "." + k
+				}
+				m[path] = true
+				walk(path, e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				walk(prefix+"[]", e)
+			}
+		}
+	}
+	walk("", v)
+	return m
+}
@@ -0,0 +1,242 @@
+package actionstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/google"
+)
+
+// Response is an encoded response decoded for assertions. Each assertion reports a
+// failure to t with the response's JSON and returns the Response so calls chain.
+type Response struct {
+	t testing.TB
+
+	// JSON is the response as encoded.
+	JSON []byte
+
+	// Google is the Actions on Google payload, nil for a followup event.
+	Google *google.Response
+
+	// Event is the followup event, nil for a Google payload.
+	Event *dialogflow.FollowupEventInput
+
+	// OutputContexts are the contexts the response sets.
+	OutputContexts []*OutputContext
+}
+
+// OutputContext is a Dialogflow context set by a response. A nil LifespanCount keeps
+// Dialogflow's default and zero ends the context.
+type OutputContext struct {
+	Name          string                     `json:"name"`
+	LifespanCount *int                       `json:"lifespanCount,omitempty"`
+	Parameters    map[string]json.RawMessage `json:"parameters,omitempty"`
+}
+
+type response struct {
+	dialogflow.Response
+	dialogflow.Event
+	OutputContexts []*OutputContext `json:"outputContexts"`
+}
+
+// Decode encodes enc and decodes the result, failing t if either fails.
+func Decode(t testing.TB, enc v2.Encoder) *Response {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := enc.Encode(buf); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return Read(t, buf)
+}
+
+// Read decodes a response from r, such as the body of a fulfillment webhook's reply,
+// failing t if it is not a response.
+func Read(t testing.TB, r io.Reader) *Response {
+	t.Helper()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	resp := &response{}
+	if err := json.Unmarshal(data, resp); err != nil {
+		t.Fatalf("decode: %v\n%s", err, data)
+	}
+	got := &Response{t: t, JSON: data, Event: resp.FollowupEventInput, OutputContexts: resp.OutputContexts}
+	if resp.Payload != nil {
+		got.Google = resp.Payload.Google
+	}
+	if got.Google == nil && got.Event == nil {
+		t.Fatalf("decode: neither a Google payload nor a followup event\n%s", data)
+	}
+	return got
+}
+
+// Speech returns the text to speech, or SSML, of the simple responses, joined by spaces.
+func (r *Response) Speech() string {
+	var speech []string
+	for _, item := range r.items() {
+		if s := item.SimpleResponse; s != nil {
+			if s.TextToSpeech != "" {
+				speech = append(speech, s.TextToSpeech)
+			} else if s.SSML != "" {
+				speech = append(speech, s.SSML)
+			}
+		}
+	}
+	return strings.Join(speech, " ")
+}
+
+// Suggestions returns the titles of the suggestion chips.
+func (r *Response) Suggestions() []string {
+	var titles []string
+	if r.Google != nil && r.Google.RichResponse != nil {
+		for _, s := range r.Google.RichResponse.Suggestions {
+			titles = append(titles, s.Title)
+		}
+	}
+	return titles
+}
+
+func (r *Response) items() []*google.Item {
+	if r.Google == nil || r.Google.RichResponse == nil {
+		return nil
+	}
+	return r.Google.RichResponse.Items
+}
+
+// SpeechContains checks the response says text.
+func (r *Response) SpeechContains(text string) *Response {
+	r.t.Helper()
+	if got := r.Speech(); !strings.Contains(got, text) {
+		r.fail(fmt.Sprintf("speech: want: containing %q, got: %q", text, got))
+	}
+	return r
+}
+
+// HasCard checks the response shows a basic or table card titled title.
+func (r *Response) HasCard(title string) *Response {
+	r.t.Helper()
+	var titles []string
+	for _, item := range r.items() {
+		switch {
+		case item.BasicCard != nil:
+			titles = append(titles, item.BasicCard.Title)
+		case item.TableCard != nil:
+			titles = append(titles, item.TableCard.Title)
+		}
+	}
+	for _, got := range titles {
+		if got == title {
+			return r
+		}
+	}
+	r.fail(fmt.Sprintf("card: want: %q, got: %q", title, titles))
+	return r
+}
+
+// HasSuggestions checks the response's suggestion chips are titles, in order.
+func (r *Response) HasSuggestions(titles ...string) *Response {
+	r.t.Helper()
+	if d := diff(titles, r.Suggestions()); d != "" {
+		r.fail("suggestions (-want +got):\n" + d)
+	}
+	return r
+}
+
+// ExpectsUserResponse checks whether the response keeps the microphone open.
+func (r *Response) ExpectsUserResponse(want bool) *Response {
+	r.t.Helper()
+	got := r.Google != nil && r.Google.ExpectUserResponse
+	if got != want {
+		r.fail(fmt.Sprintf("expectUserResponse: want: %v, got: %v", want, got))
+	}
+	return r
+}
+
+// SystemIntent checks the response asks for the system intent name, such as
+// actions.intent.SIGN_IN.
+func (r *Response) SystemIntent(name string) *Response {
+	r.t.Helper()
+	var got string
+	if r.Google != nil && r.Google.SystemIntent != nil {
+		got = r.Google.SystemIntent.Intent
+	}
+	if got != name {
+		r.fail(fmt.Sprintf("system intent: want: %q, got: %q", name, got))
+	}
+	return r
+}
+
+// FollowupEvent checks the response triggers the event name.
+func (r *Response) FollowupEvent(name string) *Response {
+	r.t.Helper()
+	var got string
+	if r.Event != nil {
+		got = r.Event.Name
+	}
+	if got != name {
+		r.fail(fmt.Sprintf("followup event: want: %q, got: %q", name, got))
+	}
+	return r
+}
+
+func (r *Response) fail(msg string) {
+	r.t.Helper()
+	indented := &bytes.Buffer{}
+	if err := json.Indent(indented, r.JSON, "", "  "); err != nil {
+		indented.Write(r.JSON)
+	}
+	r.t.Errorf("%s\nresponse:\n%s", msg, indented)
+}
+
+// diff returns the lines only in want prefixed with -, and those only in got with +,
+// or "" if they are equal.
+func diff(want, got []string) string {
+	// Longest common subsequence, so the diff shows moved and missing lines.
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			switch {
+			case want[i] == got[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	b := &strings.Builder{}
+	changed := false
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			fmt.Fprintf(b, "  %q\n", want[i])
+			i++
+			j++
+		case j == len(got) || (i < len(want) && lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(b, "- %q\n", want[i])
+			changed = true
+			i++
+		default:
+			fmt.Fprintf(b, "+ %q\n", got[j])
+			changed = true
+			j++
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package actionstest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2"
+)
+
+// recorder is a testing.TB that records failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestResponseAssertions(t *testing.T) {
+	Decode(t, &v2.Card{
+		RequiredResponse: "Here is your order.",
+		Title:            "Order 42",
+		Suggestions:      []string{"Cancel", "Track"},
+	}).
+		SpeechContains("your order").
+		HasCard("Order 42").
+		HasSuggestions("Cancel", "Track").
+		ExpectsUserResponse(true)
+
+	Decode(t, &v2.Signin{RequiredResponse: "To get your account details"}).
+		SystemIntent("actions.intent.SIGN_IN")
+
+	Decode(t, &v2.Event{Name: "reprompt"}).
+		FollowupEvent("reprompt").
+		ExpectsUserResponse(false)
+}
+
+func TestResponseFailures(t *testing.T) {
+	r := &recorder{TB: t}
+	Decode(r, &v2.Simple{Say: "Hello", Suggestions: []string{"Yes", "No"}}).
+		SpeechContains("Goodbye").
+		HasCard("Order").
+		HasSuggestions("Yes", "Maybe").
+		SystemIntent("actions.intent.OPTION")
+
+	if len(r.errors) != 4 {
+		t.Fatalf("want: 4 failures, got: %v", r.errors)
+	}
+	for _, want := range []string{
+		`speech: want: containing "Goodbye", got: "Hello"`,
+		`card: want: "Order"`,
+		"  \"Yes\"\n- \"Maybe\"\n+ \"No\"\n",
+		`system intent: want: "actions.intent.OPTION", got: ""`,
+	} {
+		if !strings.Contains(strings.Join(r.errors, "\n"), want) {
+			t.Errorf("want failure containing %q, got: %v", want, r.errors)
+		}
+	}
+	if !strings.Contains(r.errors[0], `"textToSpeech": "Hello"`) {
+		t.Errorf("want response in failure, got: %v", r.errors[0])
+	}
+}
+
+func TestDiff(t *testing.T) {
+	for _, tt := range []struct {
+		want, got []string
+		diff      string
+	}{
+		{[]string{"a", "b"}, []string{"a", "b"}, ""},
+		{nil, []string{"a"}, "+ \"a\"\n"},
+		{[]string{"a", "b", "c"}, []string{"a", "c"}, "  \"a\"\n- \"b\"\n  \"c\"\n"},
+	} {
+		if got := diff(tt.want, tt.got); got != tt.diff {
+			t.Errorf("want: %q, got: %q", tt.diff, got)
+		}
+	}
+}
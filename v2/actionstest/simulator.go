@@ -0,0 +1,186 @@
+package actionstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/google"
+)
+
+// DefaultLifespan is the number of turns an output context lasts when the response does
+// not give its lifespanCount.
+const DefaultLifespan = 5
+
+// Turn is one request sent by a Simulator and the response to it.
+type Turn struct {
+	Request  *dialogflow.Request
+	Response *Response
+}
+
+// Simulator plays a conversation with a fulfillment webhook in process. It carries the
+// conversation token, user storage and output contexts with their parameters from each
+// response to the next request, as the Assistant and Dialogflow would. A response that
+// does not expect a user response ends the conversation; the next turn starts a new one
+// with the same user.
+type Simulator struct {
+	t       testing.TB
+	handler http.Handler
+
+	// Configure, if set, is applied to each turn's request, e.g. to set its locale,
+	// capabilities or access token.
+	Configure func(*Builder)
+
+	// Turns are the turns played so far.
+	Turns []*Turn
+
+	user              string
+	session           string
+	conversationID    string
+	conversationToken string
+	userStorage       string
+	contexts          map[string]int
+	contextParameters map[string]map[string]json.RawMessage
+	conversations     int
+}
+
+// NewSimulator returns a Simulator of a new user talking to h, which fails t on errors.
+func NewSimulator(t testing.TB, h http.Handler) *Simulator {
+	b := NewRequest()
+	return &Simulator{
+		t:       t,
+		handler: h,
+		user:    b.payload().User.UserID,
+	}
+}
+
+// Welcome invokes the Action.
+func (s *Simulator) Welcome() *Response {
+	s.t.Helper()
+	return s.Send(Welcome())
+}
+
+// Say sends text as the user's query.
+func (s *Simulator) Say(text string) *Response {
+	s.t.Helper()
+	return s.Send(TextQuery(text))
+}
+
+// Send sends b with the conversation's state and returns the decoded response.
+func (s *Simulator) Send(b *Builder) *Response {
+	s.t.Helper()
+	typ := google.ActiveConversation
+	if s.session == "" {
+		s.conversations++
+		s.session = b.req.Session
+		s.conversationID = b.payload().Conversation.ConversationID
+		s.contexts = map[string]int{}
+		s.contextParameters = map[string]map[string]json.RawMessage{}
+		typ = google.NewConversation
+	}
+	b.Session(s.session).
+		User(s.user).
+		Conversation(s.conversationID, s.conversationToken).
+		ConversationType(typ).
+		UserStorage(s.userStorage)
+	for _, name := range s.Contexts() {
+		b.OutputContext(name)
+		for param, value := range s.contextParameters[name] {
+			b.ContextParameter(name, param, value)
+		}
+	}
+	if s.Configure != nil {
+		s.Configure(b)
+	}
+
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, b.HTTPRequest())
+	if w.Code != http.StatusOK {
+		s.t.Fatalf("turn %d: want: %v, got: %v %s", len(s.Turns)+1, http.StatusOK, w.Code, w.Body)
+	}
+	resp := Read(s.t, w.Body)
+	s.Turns = append(s.Turns, &Turn{Request: b.Build(), Response: resp})
+	s.update(resp)
+	return resp
+}
+
+// update carries the state of resp to the next turn.
+func (s *Simulator) update(resp *Response) {
+	for name, lifespan := range s.contexts {
+		if lifespan <= 1 {
+			delete(s.contexts, name)
+			delete(s.contextParameters, name)
+			continue
+		}
+		s.contexts[name] = lifespan - 1
+	}
+	for _, c := range resp.OutputContexts {
+		name := shortName(c.Name)
+		switch {
+		case c.LifespanCount == nil:
+			s.contexts[name] = DefaultLifespan
+		case *c.LifespanCount <= 0:
+			delete(s.contexts, name)
+			delete(s.contextParameters, name)
+			continue
+		default:
+			s.contexts[name] = *c.LifespanCount
+		}
+		s.contextParameters[name] = c.Parameters
+	}
+
+	g := resp.Google
+	if g == nil {
+		return
+	}
+	if g.ResetUserStorage {
+		s.userStorage = ""
+	}
+	if g.UserStorage != "" {
+		s.userStorage = g.UserStorage
+	}
+	s.conversationToken = g.ConversationToken
+	if !g.ExpectUserResponse {
+		s.session = ""
+		s.conversationToken = ""
+	}
+}
+
+// Contexts returns the short names of the active output contexts, sorted.
+func (s *Simulator) Contexts() []string {
+	var names []string
+	for name := range s.contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ContextParameters returns the parameters of the active output context name, which the
+// next turn will send.
+func (s *Simulator) ContextParameters(name string) map[string]json.RawMessage {
+	return s.contextParameters[name]
+}
+
+// ConversationToken returns the token the next turn will send.
+func (s *Simulator) ConversationToken() string {
+	return s.conversationToken
+}
+
+// UserStorage returns the user storage the next turn will send.
+func (s *Simulator) UserStorage() string {
+	return s.userStorage
+}
+
+// Conversations returns the number of conversations started so far.
+func (s *Simulator) Conversations() int {
+	return s.conversations
+}
+
+// Ended reports whether the last response ended the conversation.
+func (s *Simulator) Ended() bool {
+	return len(s.Turns) > 0 && s.session == ""
+}
@@ -0,0 +1,156 @@
+package actionstest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/fulfillment"
+	"github.com/damondouglas/go.actions/v2/google"
+)
+
+// raw is a response encoded as is, for fields the v2 encoders do not set.
+type raw map[string]interface{}
+
+func (r raw) Encode(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+func payload(expect bool, token, storage, say string) map[string]interface{} {
+	return map[string]interface{}{
+		"google": map[string]interface{}{
+			"expectUserResponse": expect,
+			"conversationToken":  token,
+			"userStorage":        storage,
+			"richResponse": map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"simpleResponse": map[string]interface{}{"textToSpeech": say}},
+				},
+			},
+		},
+	}
+}
+
+// counter counts the user's visits in user storage and the turns of the conversation in
+// its token, and asks for a name in the awaiting_name context.
+func counter() *fulfillment.Router {
+	r := fulfillment.NewRouter()
+	r.ActionsIntent(MainIntent, fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+		p := req.OriginalDetectIntentRequest.Payload
+		if p.Conversation.Type != google.NewConversation || p.Conversation.ConversationToken != "" {
+			return &v2.Simple{Say: "not a new conversation"}, nil
+		}
+		storage := p.User.UserStorage + "v"
+		return raw{
+			"payload": payload(true, "1", storage, "What is your name?"),
+			"outputContexts": []interface{}{
+				map[string]interface{}{"name": req.Session + "/contexts/awaiting_name", "lifespanCount": 1},
+			},
+		}, nil
+	}))
+	r.Fallback(fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+		p := req.OriginalDetectIntentRequest.Payload
+		if len(req.QueryResult.OutputContexts) == 0 {
+			return raw{"payload": payload(false, "", "", "Goodbye")}, nil
+		}
+		return raw{"payload": payload(true, p.Conversation.ConversationToken+"1", "", "Hello "+req.QueryResult.QueryText)}, nil
+	}))
+	return r
+}
+
+func TestSimulator(t *testing.T) {
+	s := NewSimulator(t, counter())
+	s.Configure = func(b *Builder) { b.Locale("en-GB") }
+
+	s.Welcome().SpeechContains("What is your name?").ExpectsUserResponse(true)
+	if got := s.Contexts(); len(got) != 1 || got[0] != "awaiting_name" {
+		t.Errorf("want: [awaiting_name], got: %v", got)
+	}
+	s.Say("Sam").SpeechContains("Hello Sam")
+	if got := s.ConversationToken(); got != "11" {
+		t.Errorf("want: 11, got: %v", got)
+	}
+	if got := s.Contexts(); len(got) != 0 {
+		t.Errorf("want no contexts, got: %v", got)
+	}
+	s.Say("Again").SpeechContains("Goodbye").ExpectsUserResponse(false)
+	if !s.Ended() {
+		t.Error("want conversation ended")
+	}
+
+	s.Welcome().SpeechContains("What is your name?")
+	if got := s.UserStorage(); got != "vv" {
+		t.Errorf("want: vv, got: %v", got)
+	}
+	if got := s.Conversations(); got != 2 {
+		t.Errorf("want: 2, got: %v", got)
+	}
+
+	first, second := s.Turns[0].Request, s.Turns[1].Request
+	if first.Session != second.Session {
+		t.Errorf("want: %v, got: %v", first.Session, second.Session)
+	}
+	if got := second.OriginalDetectIntentRequest.Payload.Conversation.Type; got != google.ActiveConversation {
+		t.Errorf("want: %v, got: %v", google.ActiveConversation, got)
+	}
+	if got := second.QueryResult.LanguageCode; got != "en-GB" {
+		t.Errorf("want: en-GB, got: %v", got)
+	}
+	if got := s.Turns[3].Request.Session; got == first.Session {
+		t.Errorf("want new session, got: %v", got)
+	}
+}
+
+// order remembers a pizza size in the order context's parameters, which only the request
+// JSON carries.
+func order(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Session     string
+		QueryResult struct {
+			QueryText      string
+			OutputContexts []*OutputContext
+		}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size := ""
+	for _, c := range req.QueryResult.OutputContexts {
+		if c.Name == req.Session+"/contexts/order" {
+			json.Unmarshal(c.Parameters["size"], &size)
+		}
+	}
+	if size != "" {
+		raw{"payload": payload(true, "", "", "A "+size+" pizza")}.Encode(w)
+		return
+	}
+	resp := raw{"payload": payload(true, "", "", "What size?")}
+	if req.QueryResult.QueryText != "wait" {
+		resp["outputContexts"] = []interface{}{
+			map[string]interface{}{
+				"name":       req.Session + "/contexts/order",
+				"parameters": map[string]interface{}{"size": req.QueryResult.QueryText},
+			},
+		}
+	}
+	resp.Encode(w)
+}
+
+func TestSimulatorContextParameters(t *testing.T) {
+	s := NewSimulator(t, http.HandlerFunc(order))
+	s.Say("large").SpeechContains("What size?")
+	if got := string(s.ContextParameters("order")["size"]); got != `"large"` {
+		t.Errorf("want: %v, got: %v", `"large"`, got)
+	}
+	for i := 0; i < DefaultLifespan; i++ {
+		s.Say("wait").SpeechContains("A large pizza")
+	}
+	if got := s.ContextParameters("order"); got != nil {
+		t.Errorf("want parameters expired with the context, got: %v", got)
+	}
+}
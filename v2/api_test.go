@@ -0,0 +1,86 @@
+package v2_test
+
+import (
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/actionstest"
+)
+
+var (
+	image = &v2.Image{
+		URL:               "https://example.com/image.png",
+		AccessibilityText: "An example image",
+	}
+	items = []*v2.SelectItem{
+		{Key: "first", Synonyms: []string{"one"}, Title: "First", Description: "The first item", Image: image},
+		{Key: "second", Synonyms: []string{"two"}, Title: "Second", Description: "The second item"},
+	}
+	suggestions = []string{"Yes", "No"}
+)
+
+func TestEncodeGolden(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		enc  v2.Encoder
+	}{
+		{"event", &v2.Event{
+			Name:         "reprompt",
+			Parameters:   map[string]string{"count": "2"},
+			LanguageCode: v2.EnglishUS,
+		}},
+		{"simple", &v2.Simple{
+			Say:         "Hello",
+			Display:     "Hello!",
+			Suggestions: suggestions,
+		}},
+		{"card", &v2.Card{
+			RequiredResponse: "Here is your order.",
+			Title:            "Order 42",
+			Subtitle:         "Shipped",
+			FormattedText:    "Arrives **tomorrow**.",
+			Image:            image,
+			Button:           &v2.Button{Title: "Track", URL: "https://example.com/track"},
+			Suggestions:      suggestions,
+		}},
+		{"carousel_browse", &v2.CarouselBrowse{
+			RequiredResponse: "Here are some articles.",
+			Items: []*v2.CarouselBrowseItem{
+				{Title: "First", URL: "https://example.com/1", Description: "The first article", Footer: "1 min read", Image: image},
+				{Title: "Second", URL: "https://example.com/2", Description: "The second article"},
+			},
+			Suggestions: suggestions,
+		}},
+		{"select", &v2.Select{
+			RequiredResponse: "Which one?",
+			Items:            items,
+			Suggestions:      suggestions,
+		}},
+		{"list", &v2.List{
+			RequiredResponse: "Which one?",
+			Items:            items,
+			Suggestions:      suggestions,
+		}},
+		{"confirmation", &v2.Confirmation{
+			RequiredResponse: "Are you sure?",
+			ConfirmationText: "Delete everything?",
+			Suggestions:      suggestions,
+		}},
+		{"signin", &v2.Signin{
+			RequiredResponse: "To get your account details",
+		}},
+		{"media", &v2.Media{
+			RequiredResponse: "Here is the episode.",
+			Type:             v2.AudioType,
+			URL:              "https://example.com/episode.mp3",
+			Description:      "Episode 1",
+			Icon:             image,
+			Title:            "Pilot",
+			Suggestions:      suggestions,
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			actionstest.Golden(t, tt.name, tt.enc)
+		})
+	}
+}
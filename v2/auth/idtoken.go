@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/fulfillment"
+	"github.com/damondouglas/go.actions/v2/logger"
+)
+
+type contextKey int
+
+const (
+	identityKey contextKey = iota
+)
+
+// Identity is the verified profile carried by a Google Sign-In ID token.
+type Identity struct {
+	Claims
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+	GivenName     string `json:"given_name,omitempty"`
+	FamilyName    string `json:"family_name,omitempty"`
+	Picture       string `json:"picture,omitempty"`
+	Locale        string `json:"locale,omitempty"`
+}
+
+// IDTokenConfig configures verification of Google Sign-In ID tokens.
+type IDTokenConfig struct {
+	// ClientID is the OAuth client ID of the Action, the expected audience of the token.
+	ClientID string
+
+	// JWKSURL serves the signing keys. Defaults to GoogleJWKSURL.
+	JWKSURL string
+
+	// JWKSFile reads the signing keys from a local file instead of JWKSURL.
+	JWKSFile string
+
+	// Issuers overrides GoogleIssuers.
+	Issuers []string
+}
+
+// IDTokenVerifier verifies Google Sign-In ID tokens such as google.User.IDToken.
+type IDTokenVerifier struct {
+	Verifier *Verifier
+}
+
+// NewIDTokenVerifier returns an IDTokenVerifier for cfg. It returns ErrNoAudience if
+// cfg.ClientID is empty, since Google signs ID tokens for every app with the same keys.
+func NewIDTokenVerifier(cfg *IDTokenConfig) (*IDTokenVerifier, error) {
+	if cfg.ClientID == "" {
+		return nil, ErrNoAudience
+	}
+	return &IDTokenVerifier{Verifier: newVerifier(cfg.ClientID, cfg.JWKSURL, cfg.JWKSFile, cfg.Issuers)}, nil
+}
+
+// Verify checks token and returns its claims.
+func (v *IDTokenVerifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	id := &Identity{}
+	if _, err := v.Verifier.Verify(ctx, token, id); err != nil {
+		return nil, err
+	}
+	if id.Subject == "" {
+		return nil, fmt.Errorf("auth: ID token has no subject")
+	}
+	return id, nil
+}
+
+// NewIdentityContext returns a copy of ctx carrying id.
+func NewIdentityContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey, id)
+}
+
+// IdentityFromContext returns the verified identity in ctx, if any.
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey).(*Identity)
+	return id, ok
+}
+
+// VerifyUser verifies the request's google.User.IDToken, when present, and puts the
+// resulting Identity in the handler's context. Requests whose token is expired or
+// otherwise fails verification continue without an Identity, as if the user had not
+// signed in, so the handler can ask them to sign in again.
+func VerifyUser(v *IDTokenVerifier) fulfillment.Middleware {
+	return func(next fulfillment.Handler) fulfillment.Handler {
+		return fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+			token := idToken(req)
+			if token == "" {
+				return next.Fulfill(ctx, req)
+			}
+			id, err := v.Verify(ctx, token)
+			if err != nil {
+				logger.FromContext(ctx).Infof("auth: ignoring ID token: %v", err)
+				return next.Fulfill(ctx, req)
+			}
+			return next.Fulfill(NewIdentityContext(ctx, id), req)
+		})
+	}
+}
+
+func idToken(req *dialogflow.Request) string {
+	payload := req.OriginalDetectIntentRequest.Payload
+	if payload == nil || payload.User == nil {
+		return ""
+	}
+	return payload.User.IDToken
+}
@@ -0,0 +1,160 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/auth"
+	"github.com/damondouglas/go.actions/v2/auth/authtest"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/fulfillment"
+	"github.com/damondouglas/go.actions/v2/google"
+)
+
+const clientID = "123.apps.googleusercontent.com"
+
+func TestVerifyUser(t *testing.T) {
+	signer, err := authtest.NewSigner("id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := signer.Server()
+	defer srv.Close()
+
+	token, err := signer.Sign(&auth.Identity{
+		Claims: auth.Claims{
+			Issuer:    "accounts.google.com",
+			Subject:   "1234567890",
+			Audience:  auth.Audience{clientID},
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+		Email:         "user@example.com",
+		EmailVerified: true,
+		Name:          "Matt Carroll",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := auth.NewIDTokenVerifier(&auth.IDTokenConfig{
+		ClientID: clientID,
+		JWKSURL:  srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got *auth.Identity
+	h := fulfillment.Chain(fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+		got, _ = auth.IdentityFromContext(ctx)
+		return &v2.Simple{}, nil
+	}), auth.VerifyUser(v))
+
+	req := &dialogflow.Request{}
+	req.OriginalDetectIntentRequest.Payload = &google.Request{
+		User: &google.User{IDToken: token},
+	}
+	if _, err := h.Fulfill(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Subject != "1234567890" || got.Email != "user@example.com" || !got.EmailVerified {
+		t.Errorf("want verified identity, got: %+v", got)
+	}
+
+	got = nil
+	req.OriginalDetectIntentRequest.Payload.User.IDToken = token[:len(token)-4] + "AAAA"
+	if _, err := h.Fulfill(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("want no identity for tampered token, got: %+v", got)
+	}
+}
+
+func TestVerifyUserExpired(t *testing.T) {
+	signer, err := authtest.NewSigner("id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := signer.Server()
+	defer srv.Close()
+	v, err := auth.NewIDTokenVerifier(&auth.IDTokenConfig{
+		ClientID: clientID,
+		JWKSURL:  srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := signer.Sign(&auth.Identity{
+		Claims: auth.Claims{
+			Issuer:    "accounts.google.com",
+			Subject:   "1234567890",
+			Audience:  auth.Audience{clientID},
+			ExpiresAt: time.Now().Add(-time.Hour).Unix(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(context.Background(), token); !errors.Is(err, auth.ErrExpired) {
+		t.Errorf("want: %v, got: %v", auth.ErrExpired, err)
+	}
+
+	called := false
+	h := fulfillment.Chain(fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+		called = true
+		if id, ok := auth.IdentityFromContext(ctx); ok {
+			t.Errorf("want no identity, got: %+v", id)
+		}
+		return &v2.Simple{}, nil
+	}), auth.VerifyUser(v))
+	req := &dialogflow.Request{}
+	req.OriginalDetectIntentRequest.Payload = &google.Request{
+		User: &google.User{IDToken: token},
+	}
+	if _, err := h.Fulfill(context.Background(), req); err != nil {
+		t.Errorf("want expired token ignored, got: %v", err)
+	}
+	if !called {
+		t.Error("want handler called")
+	}
+}
+
+func TestIDTokenAudience(t *testing.T) {
+	if _, err := auth.NewIDTokenVerifier(&auth.IDTokenConfig{}); err != auth.ErrNoAudience {
+		t.Errorf("want: %v, got: %v", auth.ErrNoAudience, err)
+	}
+
+	signer, err := authtest.NewSigner("id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := signer.Server()
+	defer srv.Close()
+	v, err := auth.NewIDTokenVerifier(&auth.IDTokenConfig{
+		ClientID: clientID,
+		JWKSURL:  srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := signer.Sign(&auth.Identity{
+		Claims: auth.Claims{
+			Issuer:    "accounts.google.com",
+			Subject:   "1234567890",
+			Audience:  auth.Audience{"456.apps.googleusercontent.com"},
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(context.Background(), token); err != auth.ErrAudience {
+		t.Errorf("want: %v, got: %v", auth.ErrAudience, err)
+	}
+}
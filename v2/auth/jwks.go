@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// GoogleJWKSURL serves the keys Google signs its JWTs with.
+	GoogleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+	// DefaultKeyTTL is how long keys are cached when the key set's response has no max-age.
+	DefaultKeyTTL = time.Hour
+
+	// FetchTimeout bounds each fetch of a remote key set.
+	FetchTimeout = 10 * time.Second
+
+	// minRefresh limits how often an unknown key ID or a failing key set can force a
+	// refresh.
+	minRefresh = time.Minute
+)
+
+var maxAge = regexp.MustCompile(`max-age=(\d+)`)
+
+// KeySet is a cached JSON Web Key Set loaded from a URL or a local file. Concurrent
+// callers share one refresh, and keep being served the cached keys while the set cannot
+// be refreshed.
+type KeySet struct {
+	URL  string
+	File string
+
+	// Client fetches URL, with requests timing out after FetchTimeout. Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+	TTL    time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expiry    time.Time
+	refreshed time.Time
+	fetch     *fetch
+}
+
+// fetch is a refresh in progress.
+type fetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewRemoteKeySet returns a KeySet fetched from url.
+func NewRemoteKeySet(url string) *KeySet {
+	return &KeySet{URL: url}
+}
+
+// NewFileKeySet returns a KeySet read from path.
+func NewFileKeySet(path string) *KeySet {
+	return &KeySet{File: path}
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwks struct {
+	Keys []*jwk `json:"keys"`
+}
+
+// Key returns the RSA public key with id kid, refreshing the set when it has expired or
+// does not contain kid. If the refresh fails, a cached key is still returned. Key waits
+// for the refresh until ctx is done.
+func (s *KeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	now := time.Now()
+	key, ok := s.keys[kid]
+	if ok && now.Before(s.expiry) {
+		s.mu.Unlock()
+		return key, nil
+	}
+	f := s.fetch
+	if f == nil {
+		if now.Sub(s.refreshed) < minRefresh && (ok || now.Before(s.expiry)) {
+			s.mu.Unlock()
+			if ok {
+				return key, nil
+			}
+			return nil, ErrUnknownKey
+		}
+		f = &fetch{done: make(chan struct{})}
+		s.fetch = f
+		go s.refresh(f)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	s.mu.Lock()
+	key, ok = s.keys[kid]
+	s.mu.Unlock()
+	switch {
+	case ok:
+		return key, nil
+	case f.err != nil:
+		return nil, f.err
+	}
+	return nil, ErrUnknownKey
+}
+
+// refresh loads the set, keeping the cached keys if it fails, and completes f.
+func (s *KeySet) refresh(f *fetch) {
+	ctx, cancel := context.WithTimeout(context.Background(), FetchTimeout)
+	defer cancel()
+	data, ttl, err := s.load(ctx)
+	var keys map[string]*rsa.PublicKey
+	if err == nil {
+		keys, err = ParseKeySet(data)
+	}
+	if ttl == 0 {
+		ttl = s.TTL
+	}
+	if ttl == 0 {
+		ttl = DefaultKeyTTL
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	s.refreshed = now
+	if err == nil {
+		s.keys = keys
+		s.expiry = now.Add(ttl)
+	}
+	s.fetch = nil
+	f.err = err
+	s.mu.Unlock()
+	close(f.done)
+}
+
+func (s *KeySet) load(ctx context.Context) (data []byte, ttl time.Duration, err error) {
+	if s.File != "" {
+		data, err = ioutil.ReadFile(s.File)
+		return data, 0, err
+	}
+	if s.URL == "" {
+		return nil, 0, fmt.Errorf("auth: key set has neither URL nor File")
+	}
+	req, err := http.NewRequest("GET", s.URL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("auth: fetching %s: %s", s.URL, resp.Status)
+	}
+	if data, err = ioutil.ReadAll(resp.Body); err != nil {
+		return nil, 0, err
+	}
+	if m := maxAge.FindStringSubmatch(resp.Header.Get("Cache-Control")); m != nil {
+		if seconds, err := strconv.Atoi(m[1]); err == nil {
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+	return data, ttl, nil
+}
+
+// ParseKeySet parses the RSA keys of a JSON Web Key Set, keyed by key ID.
+func ParseKeySet(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("auth: key %q: %v", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("auth: key %q: %v", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
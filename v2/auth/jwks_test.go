@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/damondouglas/go.actions/v2/auth/authtest"
+)
+
+func TestKeySetServesCachedKeysOnFailure(t *testing.T) {
+	signer, err := authtest.NewSigner("cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failing int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(signer.JWKS())
+	}))
+	defer srv.Close()
+
+	s := NewRemoteKeySet(srv.URL)
+	ctx := context.Background()
+	if _, err := s.Key(ctx, "cached"); err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&failing, 1)
+	s.mu.Lock()
+	s.expiry = time.Now().Add(-time.Second)
+	s.refreshed = time.Now().Add(-2 * minRefresh)
+	s.mu.Unlock()
+
+	if _, err := s.Key(ctx, "cached"); err != nil {
+		t.Errorf("want cached key, got: %v", err)
+	}
+	if _, err := s.Key(ctx, "other"); err == nil {
+		t.Error("want error for a key that was never cached")
+	}
+}
+
+func TestKeySetSharesRefresh(t *testing.T) {
+	signer, err := authtest.NewSigner("shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		w.Write(signer.JWKS())
+	}))
+	defer srv.Close()
+	s := NewRemoteKeySet(srv.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Key(ctx, "shared"); err != context.Canceled {
+		t.Errorf("want: %v, got: %v", context.Canceled, err)
+	}
+
+	const callers = 10
+	errs := make(chan error, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Key(context.Background(), "shared")
+			errs <- err
+		}()
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if got := atomic.LoadInt32(&fetches); got != 1 {
+		t.Errorf("want: 1 fetch, got: %v", got)
+	}
+}
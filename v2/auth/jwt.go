@@ -0,0 +1,203 @@
+// Package auth verifies that fulfillment and account linking requests come from Google
+// or from callers holding a configured secret.
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	algRS256 = "RS256"
+
+	// DefaultLeeway is the clock skew allowed when checking expiry and not-before times.
+	DefaultLeeway = time.Minute
+)
+
+var (
+	// ErrMalformed is returned for tokens that are not a well-formed JWS compact serialization.
+	ErrMalformed = errors.New("auth: malformed token")
+
+	// ErrAlgorithm is returned for tokens not signed with RS256.
+	ErrAlgorithm = errors.New("auth: unsupported signing algorithm")
+
+	// ErrUnknownKey is returned when the token's key ID is not in the key set.
+	ErrUnknownKey = errors.New("auth: unknown signing key")
+
+	// ErrSignature is returned when the token's signature does not verify.
+	ErrSignature = errors.New("auth: invalid signature")
+
+	// ErrIssuer is returned when the token was issued by an unexpected issuer.
+	ErrIssuer = errors.New("auth: invalid issuer")
+
+	// ErrAudience is returned when the token was not issued for the expected audience.
+	ErrAudience = errors.New("auth: invalid audience")
+
+	// ErrExpired is returned when the token has expired or is not yet valid.
+	ErrExpired = errors.New("auth: token expired")
+
+	// ErrNoAudience is returned by a Verifier without an Audience, which would accept
+	// tokens issued for anyone.
+	ErrNoAudience = errors.New("auth: no audience configured")
+
+	// ErrNoKeys is returned by a Verifier without a KeySet.
+	ErrNoKeys = errors.New("auth: no key set configured")
+)
+
+// Audience is the aud claim, which may be a single string or a list.
+type Audience []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = Audience(list)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// Contains reports whether aud is in a.
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims are the registered claims of a JWT.
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// Verifier verifies RS256 JWTs against a KeySet.
+type Verifier struct {
+	Keys     *KeySet
+	Issuers  []string
+	Audience string
+	Leeway   time.Duration
+	Now      func() time.Time
+}
+
+// Verify checks token's signature, issuer, audience and expiry, and decodes its payload
+// into claims if claims is not nil.
+func (v *Verifier) Verify(ctx context.Context, token string, claims interface{}) (*Claims, error) {
+	if v.Keys == nil {
+		return nil, ErrNoKeys
+	}
+	if v.Audience == "" {
+		return nil, ErrNoAudience
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrMalformed
+	}
+	if h.Alg != algRS256 {
+		return nil, ErrAlgorithm
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	key, err := v.Keys.Key(ctx, h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig); err != nil {
+		return nil, ErrSignature
+	}
+
+	std := &Claims{}
+	if err := decodeSegment(parts[1], std); err != nil {
+		return nil, ErrMalformed
+	}
+	if err := v.validate(std); err != nil {
+		return nil, err
+	}
+	if claims != nil {
+		if err := decodeSegment(parts[1], claims); err != nil {
+			return nil, ErrMalformed
+		}
+	}
+	return std, nil
+}
+
+func (v *Verifier) validate(c *Claims) error {
+	if len(v.Issuers) > 0 && !contains(v.Issuers, c.Issuer) {
+		return ErrIssuer
+	}
+	if !c.Audience.Contains(v.Audience) {
+		return ErrAudience
+	}
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	leeway := v.Leeway
+	if leeway == 0 {
+		leeway = DefaultLeeway
+	}
+	if c.ExpiresAt == 0 || now.Add(-leeway).After(time.Unix(c.ExpiresAt, 0)) {
+		return ErrExpired
+	}
+	if c.NotBefore != 0 && now.Add(leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return ErrExpired
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"net/http"
+)
+
+const (
+	// SignatureHeader carries the JWT Google signs fulfillment requests with.
+	SignatureHeader = "Google-Assistant-Signature"
+)
+
+// GoogleIssuers are the issuers of JWTs signed by Google.
+var GoogleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// SignatureConfig configures verification of the Google-Assistant-Signature header.
+type SignatureConfig struct {
+	// ProjectID is the Actions project ID, the expected audience of the signature.
+	ProjectID string
+
+	// JWKSURL serves the signing keys. Defaults to GoogleJWKSURL.
+	JWKSURL string
+
+	// JWKSFile reads the signing keys from a local file instead of JWKSURL.
+	JWKSFile string
+
+	// Issuers overrides GoogleIssuers.
+	Issuers []string
+}
+
+// NewSignatureVerifier returns a Verifier for the Google-Assistant-Signature header. It
+// returns ErrNoAudience if cfg.ProjectID is empty, since Google signs requests to every
+// Action with the same keys.
+func NewSignatureVerifier(cfg *SignatureConfig) (*Verifier, error) {
+	if cfg.ProjectID == "" {
+		return nil, ErrNoAudience
+	}
+	return newVerifier(cfg.ProjectID, cfg.JWKSURL, cfg.JWKSFile, cfg.Issuers), nil
+}
+
+// newVerifier returns a Verifier of Google-signed tokens for audience, with keys from
+// jwksURL or jwksFile, if set.
+func newVerifier(audience, jwksURL, jwksFile string, issuers []string) *Verifier {
+	keys := NewRemoteKeySet(GoogleJWKSURL)
+	if jwksURL != "" {
+		keys = NewRemoteKeySet(jwksURL)
+	}
+	if jwksFile != "" {
+		keys = NewFileKeySet(jwksFile)
+	}
+	if len(issuers) == 0 {
+		issuers = GoogleIssuers
+	}
+	return &Verifier{
+		Keys:     keys,
+		Issuers:  issuers,
+		Audience: audience,
+	}
+}
+
+// RequireSignature rejects requests without a valid Google-Assistant-Signature with 401.
+func RequireSignature(v *Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get(SignatureHeader)
+			if token == "" {
+				http.Error(w, "auth: missing "+SignatureHeader, http.StatusUnauthorized)
+				return
+			}
+			if _, err := v.Verify(r.Context(), token, nil); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
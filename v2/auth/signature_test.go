@@ -0,0 +1,130 @@
+package auth_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/damondouglas/go.actions/v2/auth"
+	"github.com/damondouglas/go.actions/v2/auth/authtest"
+)
+
+const projectID = "gallery-67d5b"
+
+func signatureClaims(aud string, exp time.Time) *auth.Claims {
+	return &auth.Claims{
+		Issuer:    "https://accounts.google.com",
+		Audience:  auth.Audience{aud},
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: exp.Unix(),
+	}
+}
+
+func TestRequireSignature(t *testing.T) {
+	signer, err := authtest.NewSigner("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := signer.Server()
+	defer srv.Close()
+
+	v, err := auth.NewSignatureVerifier(&auth.SignatureConfig{
+		ProjectID: projectID,
+		JWKSURL:   srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := auth.RequireSignature(v)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	valid, _ := signer.Sign(signatureClaims(projectID, time.Now().Add(time.Hour)))
+	otherProject, _ := signer.Sign(signatureClaims("other-project", time.Now().Add(time.Hour)))
+	expired, _ := signer.Sign(signatureClaims(projectID, time.Now().Add(-time.Hour)))
+
+	other, err := authtest.NewSigner("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, _ := other.Sign(signatureClaims(projectID, time.Now().Add(time.Hour)))
+
+	for _, tt := range []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"missing", "", http.StatusUnauthorized},
+		{"audience", otherProject, http.StatusUnauthorized},
+		{"expired", expired, http.StatusUnauthorized},
+		{"forged", forged, http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest("POST", "/action", nil)
+		if tt.token != "" {
+			r.Header.Set(auth.SignatureHeader, tt.token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: want: %v, got: %v", tt.name, tt.want, w.Code)
+		}
+	}
+}
+
+func TestFileKeySet(t *testing.T) {
+	signer, err := authtest.NewSigner("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(path, signer.JWKS(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := auth.NewSignatureVerifier(&auth.SignatureConfig{
+		ProjectID: projectID,
+		JWKSFile:  path,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ := signer.Sign(signatureClaims(projectID, time.Now().Add(time.Hour)))
+	if _, err := v.Verify(context.Background(), token, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifierFailsClosed(t *testing.T) {
+	if _, err := auth.NewSignatureVerifier(&auth.SignatureConfig{}); err != auth.ErrNoAudience {
+		t.Errorf("want: %v, got: %v", auth.ErrNoAudience, err)
+	}
+
+	signer, err := authtest.NewSigner("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := signer.Server()
+	defer srv.Close()
+	token, _ := signer.Sign(signatureClaims(projectID, time.Now().Add(time.Hour)))
+
+	for _, tt := range []struct {
+		v    *auth.Verifier
+		want error
+	}{
+		{&auth.Verifier{Keys: auth.NewRemoteKeySet(srv.URL)}, auth.ErrNoAudience},
+		{&auth.Verifier{Audience: projectID}, auth.ErrNoKeys},
+	} {
+		if _, err := tt.v.Verify(context.Background(), token, nil); err != tt.want {
+			t.Errorf("want: %v, got: %v", tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// Authenticator reports whether a fulfillment request carries valid credentials.
+type Authenticator interface {
+	Authenticate(r *http.Request) bool
+}
+
+// AuthenticatorFunc adapts a function to an Authenticator.
+type AuthenticatorFunc func(r *http.Request) bool
+
+// Authenticate calls f(r).
+func (f AuthenticatorFunc) Authenticate(r *http.Request) bool {
+	return f(r)
+}
+
+// BasicAuth authenticates requests with HTTP basic auth credentials, as configured on
+// the Dialogflow fulfillment webhook. Credentials maps usernames to passwords, so several
+// may be accepted while rotating.
+type BasicAuth struct {
+	Realm       string
+	Credentials map[string]string
+}
+
+// Authenticate implements Authenticator.
+func (a *BasicAuth) Authenticate(r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	want, ok := a.Credentials[user]
+	if !ok {
+		return false
+	}
+	return equal(password, want)
+}
+
+func (a *BasicAuth) challenge() string {
+	realm := a.Realm
+	if realm == "" {
+		realm = "fulfillment"
+	}
+	return `Basic realm="` + realm + `"`
+}
+
+// HeaderSecret authenticates requests carrying one of Secrets in Header, as configured
+// in the Dialogflow fulfillment webhook's custom headers. Listing several secrets lets
+// them be rotated without downtime.
+type HeaderSecret struct {
+	Header  string
+	Secrets []string
+}
+
+// Authenticate implements Authenticator.
+func (a *HeaderSecret) Authenticate(r *http.Request) bool {
+	got := r.Header.Get(a.Header)
+	if got == "" {
+		return false
+	}
+	ok := false
+	for _, secret := range a.Secrets {
+		if equal(got, secret) {
+			ok = true
+		}
+	}
+	return ok
+}
+
+// All authenticates requests that every authenticator accepts.
+func All(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) bool {
+		for _, a := range authenticators {
+			if !a.Authenticate(r) {
+				return false
+			}
+		}
+		return len(authenticators) > 0
+	})
+}
+
+// Any authenticates requests that at least one authenticator accepts.
+func Any(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) bool {
+		for _, a := range authenticators {
+			if a.Authenticate(r) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Require rejects requests a does not authenticate with 401, before they are decoded.
+func Require(a Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !a.Authenticate(r) {
+				if b, ok := a.(*BasicAuth); ok {
+					w.Header().Set("WWW-Authenticate", b.challenge())
+				}
+				http.Error(w, "auth: unauthenticated", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// equal compares secrets in constant time.
+func equal(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
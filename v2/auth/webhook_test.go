@@ -0,0 +1,65 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2/auth"
+)
+
+func TestRequire(t *testing.T) {
+	a := auth.All(
+		&auth.BasicAuth{
+			Credentials: map[string]string{"dialogflow": "s3cret"},
+		},
+		&auth.HeaderSecret{
+			Header:  "X-Fulfillment-Secret",
+			Secrets: []string{"old", "new"},
+		},
+	)
+	decoded := false
+	h := auth.Require(a)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decoded = true
+	}))
+
+	for _, tt := range []struct {
+		name     string
+		user     string
+		password string
+		secret   string
+		want     int
+	}{
+		{"valid", "dialogflow", "s3cret", "new", http.StatusOK},
+		{"rotated", "dialogflow", "s3cret", "old", http.StatusOK},
+		{"password", "dialogflow", "wrong", "new", http.StatusUnauthorized},
+		{"secret", "dialogflow", "s3cret", "stale", http.StatusUnauthorized},
+		{"missing", "", "", "", http.StatusUnauthorized},
+	} {
+		decoded = false
+		r := httptest.NewRequest("POST", "/action", nil)
+		if tt.user != "" {
+			r.SetBasicAuth(tt.user, tt.password)
+		}
+		if tt.secret != "" {
+			r.Header.Set("X-Fulfillment-Secret", tt.secret)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: want: %v, got: %v", tt.name, tt.want, w.Code)
+		}
+		if decoded != (tt.want == http.StatusOK) {
+			t.Errorf("%s: handler called: %v", tt.name, decoded)
+		}
+	}
+}
+
+func TestRequireBasicChallenge(t *testing.T) {
+	h := auth.Require(&auth.BasicAuth{})(http.NotFoundHandler())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/action", nil))
+	if got := w.Header().Get("WWW-Authenticate"); got != `Basic realm="fulfillment"` {
+		t.Errorf("want: basic challenge, got: %v", got)
+	}
+}
@@ -2,24 +2,30 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/damondouglas/go.actions/v2/identity"
-	"golang.org/x/oauth2"
 
 	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/auth"
 	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/fulfillment"
+	"github.com/damondouglas/go.actions/v2/logger"
+	aelogger "github.com/damondouglas/go.actions/v2/logger/appengine"
 	"google.golang.org/appengine"
 )
 
-type requestHandler func(*dialogflow.Request, http.ResponseWriter, *http.Request)
-
 const (
 	pathToSecretKey = "SECRET_PATH"
 	projectIDKey    = "PROJECT_ID"
 	testHostKey     = "TEST_HOST"
+	scopesKey       = "SCOPES"
+	clientIDKey     = "LINKING_CLIENT_ID"
+	clientSecretKey = "LINKING_CLIENT_SECRET"
 )
 
 var (
@@ -131,52 +137,75 @@ var (
 		},
 	}
 
-	intentMap = map[string]requestHandler{
-		"signin":         signin,
-		"profile":        profile,
-		"fulfill_signin": profile,
-	}
+	router = fulfillment.NewRouter()
 )
 
 func main() {
-	h := &identity.Handler{}
-	h.Store = store
-	http.HandleFunc("/auth", identity.AuthHandler)
+	oauthConfig, err := identity.GoogleConfig(os.Getenv(pathToSecretKey), scopes()...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	h, err := identity.New(&identity.Config{
+		ProjectID: os.Getenv(projectIDKey),
+		OAuth2:    oauthConfig,
+		BaseURL:   baseURL,
+		Clients: []*identity.Client{
+			{
+				ID:           os.Getenv(clientIDKey),
+				Secret:       os.Getenv(clientSecretKey),
+				RedirectURIs: []string{"https://oauth-redirect.googleusercontent.com/r/" + os.Getenv(projectIDKey)},
+			},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	linked := identity.RequireAccount("")
+	router.Use(fulfillment.Recover(nil, ""), fulfillment.Timeout(5*time.Second), identity.ResolveAccount(h.Introspect))
+	router.Intent("signin", fulfillment.HandlerFunc(signin))
+	router.Intent("profile", linked(fulfillment.HandlerFunc(profile)))
+	router.Intent("fulfill_signin", linked(fulfillment.HandlerFunc(profile)))
+	router.Intent("forget", linked(fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+		return h.Unlink(ctx, &v2.Simple{Say: "Your account is unlinked."})
+	})))
+	router.Fallback(fulfillment.HandlerFunc(gallery))
+
+	http.HandleFunc("/auth", h.AuthHandler)
 	http.HandleFunc("/exch", h.TokenHandler)
-	http.HandleFunc("/action", action)
+	http.HandleFunc("/token", h.GrantHandler)
+	http.HandleFunc("/revoke", h.RevocationHandler)
+	signature, err := auth.NewSignatureVerifier(&auth.SignatureConfig{
+		ProjectID: os.Getenv(projectIDKey),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/action", logger.Inject(aelogger.New)(auth.RequireSignature(signature)(router)))
 	appengine.Main()
 }
 
-func store(ctx context.Context, token *oauth2.Token) {
-	log.Println("TOKEN", token)
-}
-
-func action(w http.ResponseWriter, r *http.Request) {
-	req, err := request(r)
-	if err != nil {
-		log.Fatalf("%v", err)
+func scopes() []string {
+	scopes := strings.Split(os.Getenv(scopesKey), ",")
+	for i := range scopes {
+		scopes[i] = strings.TrimSpace(scopes[i])
 	}
+	return scopes
+}
 
-	key := extractType(req)
-
-	if encoder, ok := responseMap[key]; ok {
-		err = encoder.Encode(w)
-		if err != nil {
-			log.Fatalf("%v", err)
-		}
-	} else {
-		dispatch(req, w, r)
+func baseURL(r *http.Request) string {
+	if appengine.IsDevAppServer() {
+		return os.Getenv(testHostKey)
 	}
-
+	return "https://" + appengine.DefaultVersionHostname(appengine.NewContext(r))
 }
 
-func request(r *http.Request) (req *dialogflow.Request, err error) {
-
-	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, err
+func gallery(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+	if encoder, ok := responseMap[extractType(req)]; ok {
+		return encoder, nil
 	}
-
-	return req, nil
+	return &v2.Event{
+		Name: "goback",
+	}, nil
 }
 
 func extractType(req *dialogflow.Request) string {
@@ -197,41 +226,16 @@ func extractType(req *dialogflow.Request) string {
 	return args[0].TextValue
 }
 
-func dispatch(req *dialogflow.Request, w http.ResponseWriter, r *http.Request) {
-	intent := req.QueryResult.Intent.DisplayName
-	log.Println("INTENT: ", intent)
-
-	if handler, ok := intentMap[intent]; ok {
-		handler(req, w, r)
-	}
-
-	evt := &v2.Event{
-		Name: "goback",
-	}
-	err := evt.Encode(w)
-	if err != nil {
-		log.Fatal(err)
-	}
-}
-
-func signin(req *dialogflow.Request, w http.ResponseWriter, r *http.Request) {
-	evt := v2.Signin{
+func signin(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+	return &v2.Signin{
 		RequiredResponse: "Welcome to the gallery.",
-	}
-	err := evt.Encode(w)
-	if err != nil {
-		log.Fatal(err)
-	}
-
+	}, nil
 }
 
-func profile(req *dialogflow.Request, w http.ResponseWriter, r *http.Request) {
-	basic := v2.Simple{
-		Display: req.OriginalDetectIntentRequest.Payload.User.AccessToken,
+func profile(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+	tok, _ := identity.TokenFromContext(ctx)
+	return &v2.Simple{
+		Display: tok.Subject,
 		Say:     "Hi",
-	}
-	err := basic.Encode(w)
-	if err != nil {
-		log.Fatal(err)
-	}
+	}, nil
 }
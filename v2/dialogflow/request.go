@@ -2,39 +2,47 @@ package dialogflow
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"strings"
 
 	"github.com/damondouglas/go.actions/v2/google"
 )
 
+var errEmptyRequest = errors.New("dialogflow: empty request")
+
 // Request is the Fullfillment HTTP Request from Dialogflow
 type Request struct {
-	ResponseID  string
+	ResponseID  string `json:"responseId,omitempty"`
 	QueryResult struct {
-		QueryText                string
-		Action                   string
+		QueryText                string                     `json:"queryText,omitempty"`
+		Action                   string                     `json:"action,omitempty"`
 		RawParameterData         map[string]json.RawMessage `json:"parameters"`
-		Parameters               map[string]*Parameter
-		AllRequiredParamsPresent bool
-		OutputContexts           []struct {
-			Name string
-		}
-		Intent struct {
-			Name        string
-			DisplayName string
-		}
-		IntentDetectionConfidence float64
+		Parameters               map[string]*Parameter      `json:"-"`
+		AllRequiredParamsPresent bool                       `json:"allRequiredParamsPresent,omitempty"`
+		OutputContexts           []Context                  `json:"outputContexts,omitempty"`
+		Intent                   struct {
+			Name        string `json:"name,omitempty"`
+			DisplayName string `json:"displayName,omitempty"`
+		} `json:"intent"`
+		IntentDetectionConfidence float64 `json:"intentDetectionConfidence,omitempty"`
 		DiagnosticInfo            struct {
-		}
-		LanguageCode string
-	}
+		} `json:"diagnosticInfo"`
+		LanguageCode string `json:"languageCode,omitempty"`
+	} `json:"queryResult"`
 	OriginalDetectIntentRequest struct {
-		Source  string
-		Version string
-		Payload *google.Request
-	}
-	Session string
+		Source  string          `json:"source,omitempty"`
+		Version string          `json:"version,omitempty"`
+		Payload *google.Request `json:"payload,omitempty"`
+	} `json:"originalDetectIntentRequest"`
+	Session string `json:"session,omitempty"`
+}
+
+// Context is a Dialogflow context active in the session.
+type Context struct {
+	Name          string                     `json:"name"`
+	LifespanCount int                        `json:"lifespanCount,omitempty"`
+	Parameters    map[string]json.RawMessage `json:"parameters,omitempty"`
 }
 
 // DecodeParameters decodes parameter data.
@@ -47,9 +55,14 @@ func (r *Request) DecodeParameters() {
 
 // Decode io.Reader into Request.
 func Decode(r io.Reader) (req *Request, err error) {
-	err = json.NewDecoder(r).Decode(&req)
+	if err = json.NewDecoder(r).Decode(&req); err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return nil, errEmptyRequest
+	}
 	req.DecodeParameters()
-	return req, err
+	return req, nil
 }
 
 // Parameter argument in request.
@@ -1,10 +1,14 @@
 package dialogflow
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"path/filepath"
 	"reflect"
 	"testing"
+
+	"github.com/damondouglas/go.actions/v2/google"
 )
 
 const (
@@ -51,8 +55,8 @@ func TestWelcomeEvent(t *testing.T) {
 	want := map[string]interface{}{
 		"action": "input.welcome",
 		"intent": "actions.intent.MAIN",
-		"permissions": []string{
-			"UPDATE",
+		"permissions": []google.Permission{
+			google.UpdatePermission,
 		},
 	}
 
@@ -83,9 +87,9 @@ func TestPermissionEvent(t *testing.T) {
 		"boolValue": true,
 		"intent":    "actions.intent.PERMISSION",
 		"lastSeen":  "2018-02-26T01:38:19Z",
-		"permissions": []string{
-			"NAME",
-			"DEVICE_PRECISE_LOCATION",
+		"permissions": []google.Permission{
+			google.NamePermission,
+			google.DevicePreciseLocationPermission,
 		},
 		"displayName": "Matt Carroll",
 		"givenName":   "Matt",
@@ -281,7 +285,7 @@ func TestBase(t *testing.T) {
 			"actions.capability.MEDIA_RESPONSE_AUDIO",
 		},
 		"query":             "query from the user",
-		"inputType":         "KEYBOARD",
+		"inputType":         google.KeyboardInput,
 		"rawText":           "query from the user",
 		"textValue":         "query from the user",
 		"argumentName":      "text",
@@ -290,7 +294,7 @@ func TestBase(t *testing.T) {
 		"locale":            "en-US",
 		"userId":            "AI_yXq-AtrRh3mJX5D-G0MsVhqun",
 		"conversationId":    "1522951193000",
-		"conversationType":  "ACTIVE",
+		"conversationType":  google.ActiveConversation,
 		"conversationToken": "[]",
 		"surfaceCapabilities": []string{
 			"actions.capability.SCREEN_OUTPUT",
@@ -353,3 +357,81 @@ func TestBase(t *testing.T) {
 		t.Errorf("want: %v, got: %v", want, got)
 	}
 }
+
+// TestEncodeRoundTrip checks that a decoded request encodes back to the JSON Dialogflow
+// sent, apart from empty values.
+func TestEncodeRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(mockPath + "/*Event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, mockPath+"/request.json")
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		encoded, err := json.Marshal(req)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		want, got := nonEmpty(t, data), nonEmpty(t, encoded)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("%s:\nwant: %v\ngot:  %v", file, want, got)
+		}
+	}
+}
+
+// nonEmpty decodes JSON data without its empty strings, numbers, booleans, objects and
+// arrays.
+func nonEmpty(t *testing.T, data []byte) interface{} {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	return prune(v)
+}
+
+func prune(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if e = prune(e); e == nil {
+				delete(v, k)
+				continue
+			}
+			v[k] = e
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	case []interface{}:
+		var kept []interface{}
+		for _, e := range v {
+			if e = prune(e); e != nil {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		return kept
+	case string:
+		if v == "" {
+			return nil
+		}
+	case float64:
+		if v == 0 {
+			return nil
+		}
+	case bool:
+		if !v {
+			return nil
+		}
+	}
+	return v
+}
@@ -0,0 +1,235 @@
+package fulfillment
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"time"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/logger"
+)
+
+// DefaultApology is spoken to the user when a handler panics.
+const DefaultApology = "Sorry, something went wrong. Please try again later."
+
+// Middleware wraps a Handler with cross-cutting behavior.
+type Middleware func(Handler) Handler
+
+// Chain wraps h with mw so that mw[0] is outermost.
+func Chain(h Handler, mw ...Middleware) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// Recover responds with say when a handler panics, logging the panic to l, or to the
+// context's logger if l is nil.
+func Recover(l logger.Logger, say string) Middleware {
+	if say == "" {
+		say = DefaultApology
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (enc v2.Encoder, err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					stack := debug.Stack()
+					if p, ok := v.(*handlerPanic); ok {
+						v, stack = p.value, p.stack
+					}
+					contextLogger(ctx, l, req).Errorf("fulfillment: panic handling %s: %v\n%s", req.ResponseID, v, stack)
+					enc = &v2.Simple{
+						Say:     say,
+						Display: say,
+					}
+					err = nil
+				}
+			}()
+			return next.Fulfill(ctx, req)
+		})
+	}
+}
+
+// Logging logs each request to l, or to the context's logger if l is nil, with how long
+// its response took. Only correlation fields are logged, not what the user said or the
+// response, which may be personal; record those with the transcript package, which
+// redacts them.
+func Logging(l logger.Logger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+			l := contextLogger(ctx, l, req)
+			start := time.Now()
+			l.Infof("fulfillment: request %s intent=%q action=%q",
+				req.ResponseID, req.QueryResult.Intent.DisplayName, req.QueryResult.Action)
+			enc, err := next.Fulfill(ctx, req)
+			if err != nil {
+				l.Errorf("fulfillment: request %s failed after %v: %v", req.ResponseID, time.Since(start), err)
+				return nil, err
+			}
+			if enc == nil {
+				return nil, nil
+			}
+			return &hookEncoder{
+				enc: enc,
+				after: func(data []byte, err error) {
+					if err != nil {
+						l.Errorf("fulfillment: response %s failed to encode: %v", req.ResponseID, err)
+						return
+					}
+					l.Infof("fulfillment: response %s in %v (%d bytes)", req.ResponseID, time.Since(start), len(data))
+				},
+			}, nil
+		})
+	}
+}
+
+// Timeout cancels the handler's context after d and returns the context's error
+// if the handler has not responded by then.
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			type result struct {
+				enc v2.Encoder
+				err error
+			}
+			done := make(chan result, 1)
+			panicked := make(chan *handlerPanic, 1)
+			go func() {
+				defer func() {
+					if v := recover(); v != nil {
+						panicked <- &handlerPanic{value: v, stack: debug.Stack()}
+					}
+				}()
+				enc, err := next.Fulfill(ctx, req)
+				done <- result{enc, err}
+			}()
+
+			select {
+			case r := <-done:
+				return r.enc, r.err
+			case p := <-panicked:
+				panic(p)
+			case <-ctx.Done():
+				return nil, fmt.Errorf("fulfillment: request %s: %v", req.ResponseID, ctx.Err())
+			}
+		})
+	}
+}
+
+// handlerPanic is a panic recovered from a handler's goroutine and panicked again in
+// its caller's, with the stack of the goroutine where it happened.
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *handlerPanic) Error() string {
+	return fmt.Sprintf("%v\n\nhandler goroutine:\n%s", p.value, p.stack)
+}
+
+// BeforeEncode calls fn with the handler's response before it is encoded.
+func BeforeEncode(fn func(ctx context.Context, req *dialogflow.Request, enc v2.Encoder)) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+			enc, err := next.Fulfill(ctx, req)
+			if err != nil || enc == nil {
+				return enc, err
+			}
+			return &hookEncoder{
+				enc: enc,
+				before: func() {
+					fn(ctx, req, enc)
+				},
+			}, nil
+		})
+	}
+}
+
+// AfterEncode calls fn with the encoded response, or the error encoding it.
+func AfterEncode(fn func(ctx context.Context, req *dialogflow.Request, data []byte, err error)) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+			enc, err := next.Fulfill(ctx, req)
+			if err != nil || enc == nil {
+				return enc, err
+			}
+			return &hookEncoder{
+				enc: enc,
+				after: func(data []byte, err error) {
+					fn(ctx, req, data, err)
+				},
+			}, nil
+		})
+	}
+}
+
+// hookEncoder calls before and after around encoding enc.
+type hookEncoder struct {
+	enc    v2.Encoder
+	before func()
+	after  func(data []byte, err error)
+}
+
+// Encode enc to w, calling the hooks.
+func (e *hookEncoder) Encode(w io.Writer) error {
+	if e.before != nil {
+		e.before()
+	}
+	if e.after == nil {
+		return e.enc.Encode(w)
+	}
+	buf := &bytes.Buffer{}
+	err := e.enc.Encode(buf)
+	e.after(buf.Bytes(), err)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// contextLogger returns l with req's fields, or the logger in ctx, which Serve gave
+// them, if l is nil.
+func contextLogger(ctx context.Context, l logger.Logger, req *dialogflow.Request) logger.Logger {
+	if l != nil {
+		return logger.With(l, RequestFields(req)...)
+	}
+	return logger.FromContext(ctx)
+}
+
+// RequestFields returns the fields correlating log lines with req: its response ID,
+// session, conversation ID, intent, locale and surface capabilities.
+func RequestFields(req *dialogflow.Request) []logger.Field {
+	fields := []logger.Field{
+		logger.F("responseId", req.ResponseID),
+		logger.F("session", req.Session),
+		logger.F("intent", req.QueryResult.Intent.DisplayName),
+	}
+	locale := req.QueryResult.LanguageCode
+	payload := req.OriginalDetectIntentRequest.Payload
+	if payload == nil {
+		return append(fields, logger.F("locale", locale))
+	}
+	if payload.Conversation != nil {
+		fields = append(fields, logger.F("conversationId", payload.Conversation.ConversationID))
+	}
+	if payload.User != nil && payload.User.Locale != "" {
+		locale = payload.User.Locale
+	}
+	fields = append(fields, logger.F("locale", locale))
+	if payload.Surface != nil {
+		capabilities := make([]string, len(payload.Surface.Capabilities))
+		for i, c := range payload.Surface.Capabilities {
+			capabilities[i] = c.Name
+		}
+		fields = append(fields, logger.F("capabilities", capabilities))
+	}
+	return fields
+}
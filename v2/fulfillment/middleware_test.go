@@ -0,0 +1,151 @@
+package fulfillment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/logger"
+	"github.com/damondouglas/go.actions/v2/logger/loggertest"
+)
+
+func TestRecover(t *testing.T) {
+	l := &loggertest.Logger{}
+	r := NewRouter()
+	r.Use(Recover(l, ""))
+	r.Fallback(HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+		panic("boom")
+	}))
+
+	w := serve(t, r, "request.json")
+	if got := spoken(t, w); got != DefaultApology {
+		t.Errorf("want: %v, got: %v", DefaultApology, got)
+	}
+	if entries := l.Entries(); len(entries) != 1 || !strings.Contains(entries[0].Message, "boom") {
+		t.Errorf("want panic logged, got: %v", entries)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	r := NewRouter()
+	r.Use(Timeout(10 * time.Millisecond))
+	r.Fallback(HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return &v2.Simple{Say: "too late"}, nil
+	}))
+
+	w := serve(t, r, "request.json")
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("want: %v, got: %v", http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestTimeoutPanicStack(t *testing.T) {
+	l := &loggertest.Logger{}
+	r := NewRouter()
+	r.Use(Recover(l, ""), Timeout(time.Second))
+	r.Fallback(HandlerFunc(panicking))
+
+	w := serve(t, r, "request.json")
+	if got := spoken(t, w); got != DefaultApology {
+		t.Errorf("want: %v, got: %v", DefaultApology, got)
+	}
+	entries := l.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("want panic logged, got: %v", entries)
+	}
+	if msg := entries[0].Message; !strings.Contains(msg, "boom") || !strings.Contains(msg, "panicking") {
+		t.Errorf("want the stack where the handler panicked, got: %v", msg)
+	}
+}
+
+func panicking(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+	panic("boom")
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return BeforeEncode(func(ctx context.Context, req *dialogflow.Request, enc v2.Encoder) {
+			order = append(order, name)
+		})
+	}
+	var encoded string
+	r := NewRouter()
+	r.Use(AfterEncode(func(ctx context.Context, req *dialogflow.Request, data []byte, err error) {
+		encoded = string(data)
+	}), mark("outer"), mark("inner"))
+	r.Fallback(say("hello"))
+
+	serve(t, r, "request.json")
+
+	if strings.Join(order, ",") != "outer,inner" {
+		t.Errorf("want: outer,inner, got: %v", order)
+	}
+	if !strings.Contains(encoded, `"textToSpeech":"hello"`) {
+		t.Errorf("want encoded response, got: %v", encoded)
+	}
+}
+
+func TestRequestFields(t *testing.T) {
+	l := &loggertest.Logger{}
+	r := NewRouter()
+	r.Use(Logging(nil))
+	r.Fallback(HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+		logger.FromContext(ctx).Infof("handled")
+		return &v2.Simple{Say: "hello"}, nil
+	}))
+
+	h := logger.Inject(func(*http.Request) logger.Logger { return l })(r)
+	serve(t, h, "request.json")
+
+	entries := l.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("want: 3 entries, got: %v", entries)
+	}
+	for _, e := range entries {
+		for key, want := range map[string]interface{}{
+			"responseId":     "68efa569-4ba1-4b7f-9b1b-ac2865deb539",
+			"session":        "projects/integrationfulfillmenttest/agent/sessions/1522951193000",
+			"conversationId": "1522951193000",
+			"intent":         "Name of Dialogflow Intent",
+			"locale":         "en-US",
+		} {
+			if got, _ := e.Field(key); got != want {
+				t.Errorf("%v: %s: want: %v, got: %v", e, key, want, got)
+			}
+		}
+		if got, _ := e.Field("capabilities"); !strings.Contains(fmt.Sprint(got), "actions.capability.SCREEN_OUTPUT") {
+			t.Errorf("%v: want capabilities, got: %v", e, got)
+		}
+	}
+}
+
+func TestLoggingFields(t *testing.T) {
+	l := &loggertest.Logger{}
+	r := NewRouter()
+	r.Use(Logging(l))
+	r.Fallback(say("hello"))
+
+	serve(t, r, "request.json")
+	entries := l.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("want: 2 entries, got: %v", entries)
+	}
+	for _, e := range entries {
+		if got, _ := e.Field("conversationId"); got != "1522951193000" {
+			t.Errorf("%v: want: 1522951193000, got: %v", e, got)
+		}
+		for _, private := range []string{"query from the user", "hello"} {
+			if strings.Contains(e.Message, private) {
+				t.Errorf("want %q not logged, got: %v", private, e)
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package fulfillment routes Dialogflow fulfillment requests to handlers.
+package fulfillment
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/logger"
+)
+
+const (
+	contentTypeKey  = "Content-Type"
+	contentTypeJSON = "application/json; charset=utf-8"
+	apiVersionKey   = "Google-Actions-API-Version"
+	apiVersionValue = "2"
+)
+
+var (
+	// ErrNoHandler is returned when no handler matches the request and no fallback is set.
+	ErrNoHandler = errors.New("fulfillment: no handler for request")
+
+	// ErrNoResponse is returned when a handler returns neither a response nor an error.
+	ErrNoResponse = errors.New("fulfillment: handler returned no response")
+)
+
+// Handler responds to a fulfillment request.
+type Handler interface {
+	Fulfill(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error)
+}
+
+// HandlerFunc adapts a function to a Handler.
+type HandlerFunc func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error)
+
+// Fulfill calls f(ctx, req).
+func (f HandlerFunc) Fulfill(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+	return f(ctx, req)
+}
+
+// Router dispatches requests to handlers registered by Dialogflow intent display name,
+// Dialogflow action, event or Actions intent, in that order of precedence.
+type Router struct {
+	intents        map[string]Handler
+	actions        map[string]Handler
+	events         map[string]Handler
+	actionsIntents map[string]Handler
+	fallback       Handler
+	middleware     []Middleware
+}
+
+// NewRouter returns an empty Router.
+func NewRouter() *Router {
+	return &Router{
+		intents:        map[string]Handler{},
+		actions:        map[string]Handler{},
+		events:         map[string]Handler{},
+		actionsIntents: map[string]Handler{},
+	}
+}
+
+// Intent registers h for the Dialogflow intent with displayName.
+func (r *Router) Intent(displayName string, h Handler) {
+	r.intents[displayName] = h
+}
+
+// Action registers h for the Dialogflow action.
+func (r *Router) Action(action string, h Handler) {
+	r.actions[action] = h
+}
+
+// Event registers h for a Dialogflow event, matched against the query text
+// Dialogflow sends when an intent is triggered by an event.
+func (r *Router) Event(name string, h Handler) {
+	r.events[name] = h
+}
+
+// ActionsIntent registers h for an Actions intent such as actions.intent.MAIN.
+func (r *Router) ActionsIntent(name string, h Handler) {
+	r.actionsIntents[name] = h
+}
+
+// Fallback registers h for requests no other handler matches.
+func (r *Router) Fallback(h Handler) {
+	r.fallback = h
+}
+
+// Use wraps every request the router handles with mw, in order.
+func (r *Router) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Handler returns the handler for req, or nil if none matches.
+func (r *Router) Handler(req *dialogflow.Request) Handler {
+	if h, ok := r.intents[req.QueryResult.Intent.DisplayName]; ok {
+		return h
+	}
+	if h, ok := r.actions[req.QueryResult.Action]; ok && req.QueryResult.Action != "" {
+		return h
+	}
+	if h, ok := r.events[req.QueryResult.QueryText]; ok {
+		return h
+	}
+	if h, ok := r.actionsIntents[actionsIntent(req)]; ok {
+		return h
+	}
+	return r.fallback
+}
+
+// Fulfill calls the handler for req, wrapped by the router's middleware.
+func (r *Router) Fulfill(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+	return Chain(HandlerFunc(r.dispatch), r.middleware...).Fulfill(ctx, req)
+}
+
+func (r *Router) dispatch(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+	h := r.Handler(req)
+	if h == nil {
+		return nil, ErrNoHandler
+	}
+	return h.Fulfill(ctx, req)
+}
+
+// ServeHTTP decodes the request, calls its handler and writes the encoded response.
+func (r *Router) ServeHTTP(w http.ResponseWriter, hr *http.Request) {
+	Serve(r, w, hr)
+}
+
+// Serve decodes hr, calls h and writes the encoded response to w. The context's logger
+// is given the request's RequestFields. Errors are logged to it; the response carries
+// only the status text, so handler errors are not disclosed to the caller.
+func Serve(h Handler, w http.ResponseWriter, hr *http.Request) {
+	req, err := dialogflow.Decode(hr.Body)
+	if err != nil {
+		logger.FromContext(hr.Context()).Errorf("fulfillment: decoding request: %v", err)
+		httpError(w, http.StatusBadRequest)
+		return
+	}
+
+	ctx := hr.Context()
+	l := logger.With(logger.FromContext(ctx), RequestFields(req)...)
+	ctx = logger.NewContext(ctx, l)
+	enc, err := h.Fulfill(ctx, req)
+	if err == nil && enc == nil {
+		err = ErrNoResponse
+	}
+	if errors.Is(err, ErrNoHandler) {
+		l.Errorf("fulfillment: request %s: %v", req.ResponseID, err)
+		httpError(w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		l.Errorf("fulfillment: request %s: %v", req.ResponseID, err)
+		httpError(w, http.StatusInternalServerError)
+		return
+	}
+
+	if err := Write(w, enc); err != nil {
+		l.Errorf("fulfillment: response %s failed to encode: %v", req.ResponseID, err)
+	}
+}
+
+// Write encodes enc and writes it to w with the headers the Assistant expects. If enc
+// fails to encode, w gets an internal server error and the error is returned.
+func Write(w http.ResponseWriter, enc v2.Encoder) error {
+	buf := &bytes.Buffer{}
+	if err := enc.Encode(buf); err != nil {
+		httpError(w, http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set(contentTypeKey, contentTypeJSON)
+	w.Header().Set(apiVersionKey, apiVersionValue)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+	return nil
+}
+
+func httpError(w http.ResponseWriter, code int) {
+	http.Error(w, http.StatusText(code), code)
+}
+
+func actionsIntent(req *dialogflow.Request) string {
+	payload := req.OriginalDetectIntentRequest.Payload
+	if payload == nil || len(payload.Inputs) == 0 || payload.Inputs[0] == nil {
+		return ""
+	}
+	return payload.Inputs[0].Intent
+}
@@ -0,0 +1,102 @@
+package fulfillment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/logger"
+	"github.com/damondouglas/go.actions/v2/logger/loggertest"
+)
+
+const (
+	mockPath = "../mock/v2"
+)
+
+func say(text string) Handler {
+	return HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+		return &v2.Simple{Say: text}, nil
+	})
+}
+
+func serve(t *testing.T, h http.Handler, mock string) *httptest.ResponseRecorder {
+	f, err := os.Open(mockPath + "/" + mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", f))
+	return w
+}
+
+func spoken(t *testing.T, w *httptest.ResponseRecorder) string {
+	var resp *dialogflow.Response
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Payload.Google.RichResponse.Items[0].SimpleResponse.TextToSpeech
+}
+
+func TestRouterPrecedence(t *testing.T) {
+	r := NewRouter()
+	r.ActionsIntent("actions.intent.TEXT", say("actions intent"))
+	r.Action("action.name.of.matched.dialogflow.intent", say("action"))
+	r.Intent("Name of Dialogflow Intent", say("intent"))
+
+	w := serve(t, r, "request.json")
+	if got := spoken(t, w); got != "intent" {
+		t.Errorf("want: intent, got: %v", got)
+	}
+	if got := w.Header().Get(contentTypeKey); got != contentTypeJSON {
+		t.Errorf("want: %v, got: %v", contentTypeJSON, got)
+	}
+}
+
+func TestRouterActionsIntent(t *testing.T) {
+	r := NewRouter()
+	r.ActionsIntent("actions.intent.SIGN_IN", say("signed in"))
+	r.Fallback(say("fallback"))
+
+	if got := spoken(t, serve(t, r, "SignInEvent.json")); got != "signed in" {
+		t.Errorf("want: signed in, got: %v", got)
+	}
+	if got := spoken(t, serve(t, r, "NoInputEvent.json")); got != "fallback" {
+		t.Errorf("want: fallback, got: %v", got)
+	}
+}
+
+func TestRouterNoHandler(t *testing.T) {
+	w := serve(t, NewRouter(), "request.json")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("want: %v, got: %v", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestServeHidesErrors(t *testing.T) {
+	l := &loggertest.Logger{}
+	r := NewRouter()
+	r.Fallback(HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+		return nil, errors.New("database password is hunter2")
+	}))
+	h := logger.Inject(func(*http.Request) logger.Logger { return l })(r)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"responseId":"1"}`)))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("want: %v, got: %v", http.StatusInternalServerError, w.Code)
+	}
+	if strings.Contains(w.Body.String(), "hunter2") {
+		t.Errorf("want error hidden, got: %s", w.Body)
+	}
+	if entries := l.Entries(); len(entries) != 1 || !strings.Contains(entries[0].Message, "hunter2") {
+		t.Errorf("want error logged, got: %v", entries)
+	}
+}
@@ -0,0 +1,278 @@
+package google
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// SignInArgument is the name of the argument sent with actions.intent.SIGN_IN.
+	SignInArgument = "SIGN_IN"
+
+	// ConfirmationArgument is the name of the argument sent with actions.intent.CONFIRMATION.
+	ConfirmationArgument = "CONFIRMATION"
+
+	// OptionArgument is the name of the argument sent with actions.intent.OPTION.
+	OptionArgument = "OPTION"
+
+	// PermissionArgument is the name of the argument sent with actions.intent.PERMISSION.
+	PermissionArgument = "PERMISSION"
+
+	// NewSurfaceArgument is the name of the argument sent with actions.intent.NEW_SURFACE.
+	NewSurfaceArgument = "NEW_SURFACE"
+
+	// TransactionDecisionArgument is the name of the argument sent with actions.intent.TRANSACTION_DECISION.
+	TransactionDecisionArgument = "TRANSACTION_DECISION_VALUE"
+
+	// MediaStatusArgument is the name of the argument sent with actions.intent.MEDIA_STATUS.
+	MediaStatusArgument = "MEDIA_STATUS"
+)
+
+const (
+	// SignInValueType is the @type of a SignInValue extension.
+	SignInValueType = "type.googleapis.com/google.actions.v2.SignInValue"
+
+	// ConfirmationValueType is the @type of a ConfirmationValue extension.
+	ConfirmationValueType = "type.googleapis.com/google.actions.v2.ConfirmationValue"
+
+	// OptionValueType is the @type of an OptionValue extension.
+	OptionValueType = "type.googleapis.com/google.actions.v2.OptionValue"
+
+	// PermissionValueType is the @type of a PermissionValue extension.
+	PermissionValueType = "type.googleapis.com/google.actions.v2.PermissionValue"
+
+	// NewSurfaceValueType is the @type of a NewSurfaceValue extension.
+	NewSurfaceValueType = "type.googleapis.com/google.actions.v2.NewSurfaceValue"
+
+	// TransactionDecisionValueType is the @type of a TransactionDecisionValue extension.
+	TransactionDecisionValueType = "type.googleapis.com/google.actions.v2.TransactionDecisionValue"
+
+	// MediaStatusType is the @type of a MediaStatus extension.
+	MediaStatusType = "type.googleapis.com/google.actions.v2.MediaStatus"
+)
+
+var (
+	// ErrNoArgument is returned when the input has no argument of the requested name.
+	ErrNoArgument = errors.New("google: argument not found")
+
+	// ErrNoExtension is returned when the argument has no extension.
+	ErrNoExtension = errors.New("google: argument has no extension")
+)
+
+// SignInValue is the result of a sign in request.
+type SignInValue struct {
+	Status SignInStatus `json:"status,omitempty"`
+}
+
+// ConfirmationValue is the user's answer to a confirmation request.
+type ConfirmationValue struct {
+	UserDecision bool `json:"userDecision,omitempty"`
+}
+
+// OptionValue is the option selected by the user.
+type OptionValue struct {
+	Key string `json:"key,omitempty"`
+}
+
+// PermissionValue is the user's answer to a permission request.
+type PermissionValue struct {
+	PermissionGranted bool `json:"permissionGranted,omitempty"`
+}
+
+// NewSurfaceValue is the result of a request to move to a new surface.
+type NewSurfaceValue struct {
+	Status NewSurfaceStatus `json:"status,omitempty"`
+}
+
+// TransactionDecisionValue is the user's decision on a proposed order.
+type TransactionDecisionValue struct {
+	CheckResult     json.RawMessage `json:"checkResult,omitempty"`
+	UserDecision    string          `json:"userDecision,omitempty"`
+	Order           json.RawMessage `json:"order,omitempty"`
+	DeliveryAddress *Location       `json:"deliveryAddress,omitempty"`
+}
+
+// MediaStatus is the status of a media response.
+type MediaStatus struct {
+	Status PlaybackStatus `json:"status,omitempty"`
+}
+
+// Status is an error reported by the Assistant in an argument, modelled on google.rpc.Status.
+type Status struct {
+	Code    int               `json:"code,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Details []json.RawMessage `json:"details,omitempty"`
+}
+
+// Error implements error.
+func (s *Status) Error() string {
+	return fmt.Sprintf("google: status %d: %s", s.Code, s.Message)
+}
+
+// ExtensionTypeError is returned when an argument extension is not of the requested @type.
+type ExtensionTypeError struct {
+	Want string
+	Got  string
+}
+
+// Error implements error.
+func (e *ExtensionTypeError) Error() string {
+	return fmt.Sprintf("google: extension is %q, not %q", e.Got, e.Want)
+}
+
+// Argument returns the first argument with name, or nil.
+func (i *Input) Argument(name string) *Argument {
+	for _, a := range i.Arguments {
+		if a != nil && a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// Err returns the argument's status as an error, or nil if it carries no error.
+func (a *Argument) Err() error {
+	if len(a.Status) == 0 {
+		return nil
+	}
+	s := &Status{}
+	if err := json.Unmarshal(a.Status, s); err != nil {
+		return err
+	}
+	if s.Code == 0 {
+		return nil
+	}
+	return s
+}
+
+// ExtensionType returns the @type of the argument's extension.
+func (a *Argument) ExtensionType() string {
+	var v struct {
+		Type string `json:"@type"`
+	}
+	if len(a.Extension) == 0 {
+		return ""
+	}
+	if err := json.Unmarshal(a.Extension, &v); err != nil {
+		return ""
+	}
+	return v.Type
+}
+
+// DecodeExtension decodes the argument's extension into v after checking it is of type typ.
+func (a *Argument) DecodeExtension(typ string, v interface{}) error {
+	if len(a.Extension) == 0 {
+		return ErrNoExtension
+	}
+	if got := a.ExtensionType(); got != typ {
+		return &ExtensionTypeError{Want: typ, Got: got}
+	}
+	return json.Unmarshal(a.Extension, v)
+}
+
+func (i *Input) argument(name string) (*Argument, error) {
+	a := i.Argument(name)
+	if a == nil {
+		return nil, ErrNoArgument
+	}
+	if err := a.Err(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (i *Input) extension(name, typ string, v interface{}) error {
+	a, err := i.argument(name)
+	if err != nil {
+		return err
+	}
+	return a.DecodeExtension(typ, v)
+}
+
+// SignInValue returns the result of actions.intent.SIGN_IN.
+func (i *Input) SignInValue() (*SignInValue, error) {
+	v := &SignInValue{}
+	if err := i.extension(SignInArgument, SignInValueType, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// ConfirmationValue returns the result of actions.intent.CONFIRMATION.
+func (i *Input) ConfirmationValue() (*ConfirmationValue, error) {
+	a, err := i.argument(ConfirmationArgument)
+	if err != nil {
+		return nil, err
+	}
+	v := &ConfirmationValue{}
+	if len(a.Extension) == 0 {
+		v.UserDecision = a.BoolValue
+		return v, nil
+	}
+	if err := a.DecodeExtension(ConfirmationValueType, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// OptionValue returns the result of actions.intent.OPTION.
+func (i *Input) OptionValue() (*OptionValue, error) {
+	a, err := i.argument(OptionArgument)
+	if err != nil {
+		return nil, err
+	}
+	v := &OptionValue{}
+	if len(a.Extension) == 0 {
+		v.Key = a.TextValue
+		return v, nil
+	}
+	if err := a.DecodeExtension(OptionValueType, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// PermissionValue returns the result of actions.intent.PERMISSION.
+func (i *Input) PermissionValue() (*PermissionValue, error) {
+	a, err := i.argument(PermissionArgument)
+	if err != nil {
+		return nil, err
+	}
+	v := &PermissionValue{}
+	if len(a.Extension) == 0 {
+		v.PermissionGranted = a.BoolValue
+		return v, nil
+	}
+	if err := a.DecodeExtension(PermissionValueType, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// NewSurfaceValue returns the result of actions.intent.NEW_SURFACE.
+func (i *Input) NewSurfaceValue() (*NewSurfaceValue, error) {
+	v := &NewSurfaceValue{}
+	if err := i.extension(NewSurfaceArgument, NewSurfaceValueType, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// TransactionDecisionValue returns the result of actions.intent.TRANSACTION_DECISION.
+func (i *Input) TransactionDecisionValue() (*TransactionDecisionValue, error) {
+	v := &TransactionDecisionValue{}
+	if err := i.extension(TransactionDecisionArgument, TransactionDecisionValueType, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// MediaStatus returns the result of actions.intent.MEDIA_STATUS.
+func (i *Input) MediaStatus() (*MediaStatus, error) {
+	v := &MediaStatus{}
+	if err := i.extension(MediaStatusArgument, MediaStatusType, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
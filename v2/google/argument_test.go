@@ -0,0 +1,113 @@
+package google
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decodeInput(t *testing.T, data string) *Input {
+	var input *Input
+	if err := json.Unmarshal([]byte(data), &input); err != nil {
+		t.Fatal(err)
+	}
+	return input
+}
+
+func TestSignInValue(t *testing.T) {
+	input := decodeInput(t, `{
+		"intent": "actions.intent.SIGN_IN",
+		"arguments": [{
+			"name": "SIGN_IN",
+			"extension": {
+				"@type": "type.googleapis.com/google.actions.v2.SignInValue",
+				"status": "OK"
+			}
+		}]
+	}`)
+
+	got, err := input.SignInValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &SignInValue{Status: "OK"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+func TestExtensionTypeMismatch(t *testing.T) {
+	input := decodeInput(t, `{
+		"arguments": [{
+			"name": "MEDIA_STATUS",
+			"extension": {
+				"@type": "type.googleapis.com/google.actions.v2.SignInValue",
+				"status": "OK"
+			}
+		}]
+	}`)
+
+	_, err := input.MediaStatus()
+	if _, ok := err.(*ExtensionTypeError); !ok {
+		t.Errorf("want: *ExtensionTypeError, got: %v", err)
+	}
+}
+
+func TestArgumentFallbacks(t *testing.T) {
+	input := decodeInput(t, `{
+		"arguments": [
+			{"name": "CONFIRMATION", "boolValue": true},
+			{"name": "OPTION", "textValue": "SELECTION_KEY_ONE"},
+			{"name": "PERMISSION", "textValue": "true", "boolValue": true}
+		]
+	}`)
+
+	confirmation, err := input.ConfirmationValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	option, err := input.OptionValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	permission, err := input.PermissionValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"confirmation": true,
+		"option":       "SELECTION_KEY_ONE",
+		"permission":   true,
+	}
+	got := map[string]interface{}{
+		"confirmation": confirmation.UserDecision,
+		"option":       option.Key,
+		"permission":   permission.PermissionGranted,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+
+	if _, err := input.SignInValue(); err != ErrNoArgument {
+		t.Errorf("want: %v, got: %v", ErrNoArgument, err)
+	}
+}
+
+func TestArgumentStatus(t *testing.T) {
+	input := decodeInput(t, `{
+		"arguments": [{
+			"name": "NEW_SURFACE",
+			"status": {"code": 7, "message": "permission denied"}
+		}]
+	}`)
+
+	_, err := input.NewSurfaceValue()
+	status, ok := err.(*Status)
+	if !ok {
+		t.Fatalf("want: *Status, got: %v", err)
+	}
+	if status.Code != 7 || status.Message != "permission denied" {
+		t.Errorf("want: 7 permission denied, got: %v", status)
+	}
+}
@@ -2,7 +2,7 @@ package google
 
 // Conversation represents the conversation data payload.
 type Conversation struct {
-	ConversationID    string
-	Type              string
-	ConversationToken string
+	ConversationID    string           `json:"conversationId,omitempty"`
+	Type              ConversationType `json:"type,omitempty"`
+	ConversationToken string           `json:"conversationToken,omitempty"`
 }
@@ -1,34 +1,56 @@
 package google
 
+import (
+	"encoding/json"
+	"reflect"
+)
+
 // Device represents information about the device the user is using to interact with the Action.
 type Device struct {
-	Location *Location
+	Location *Location `json:"location,omitempty"`
 }
 
 // Location represents a location.
 type Location struct {
 	Coordinates struct {
-		Latitude  float64
-		Longitude float64
-	}
-	FormattedAddress string
-	ZipCode          string
-	City             string
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	} `json:"coordinates"`
+	FormattedAddress string `json:"formattedAddress,omitempty"`
+	ZipCode          string `json:"zipCode,omitempty"`
+	City             string `json:"city,omitempty"`
 	PostalAddress    struct {
-		Revision           int
-		RegionCode         string
-		LanguageCode       string
-		PostalCode         string
-		SortingCode        string
-		AdministrativeArea string
-		Locality           string
-		Sublocality        string
-		AddressLines       []string
-		Recipients         []string
-		Organization       string
+		Revision           int      `json:"revision,omitempty"`
+		RegionCode         string   `json:"regionCode,omitempty"`
+		LanguageCode       string   `json:"languageCode,omitempty"`
+		PostalCode         string   `json:"postalCode,omitempty"`
+		SortingCode        string   `json:"sortingCode,omitempty"`
+		AdministrativeArea string   `json:"administrativeArea,omitempty"`
+		Locality           string   `json:"locality,omitempty"`
+		Sublocality        string   `json:"sublocality,omitempty"`
+		AddressLines       []string `json:"addressLines,omitempty"`
+		Recipients         []string `json:"recipients,omitempty"`
+		Organization       string   `json:"organization,omitempty"`
+	} `json:"postalAddress"`
+	Name        string `json:"name,omitempty"`
+	PhoneNumber string `json:"phoneNumber,omitempty"`
+	Notes       string `json:"notes,omitempty"`
+	PlaceID     string `json:"placeId,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. It omits unset coordinates and postal address.
+func (l Location) MarshalJSON() ([]byte, error) {
+	type location Location
+	v := struct {
+		location
+		Coordinates   interface{} `json:"coordinates,omitempty"`
+		PostalAddress interface{} `json:"postalAddress,omitempty"`
+	}{location: location(l)}
+	if !reflect.ValueOf(l.Coordinates).IsZero() {
+		v.Coordinates = l.Coordinates
+	}
+	if !reflect.ValueOf(l.PostalAddress).IsZero() {
+		v.PostalAddress = l.PostalAddress
 	}
-	Name        string
-	PhoneNumber string
-	Notes       string
-	PlaceID     string
+	return json.Marshal(v)
 }
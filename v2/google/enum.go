@@ -1,40 +1,357 @@
 package google
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+)
 
-// Enum implements an enumeration of string values.
-type Enum struct {
-	Basis     map[string]int
-	BasisKeys []string
-	data      []int
+// EnumError is returned when a value is not one of an enumeration's documented values.
+type EnumError struct {
+	Type  string
+	Value string
 }
 
-// UnmarshallJSON overrides to enable json encoding.
-func (e *Enum) UnmarshallJSON(data []byte) (err error) {
-	var keys []string
-	if err = json.Unmarshal(data, &keys); err != nil {
-		return err
+// Error implements error.
+func (e *EnumError) Error() string {
+	return fmt.Sprintf("google: %q is not a valid %s", e.Value, e.Type)
+}
+
+// enum validates and encodes the string values of an enumeration.
+type enum struct {
+	name   string
+	values map[string]bool
+}
+
+func newEnum(name string, values ...string) *enum {
+	e := &enum{
+		name:   name,
+		values: map[string]bool{},
 	}
-	for _, key := range keys {
-		if e.data == nil {
-			e.data = []int{}
-		}
-		e.data = append(e.data, e.Basis[key])
+	for _, v := range values {
+		e.values[v] = true
 	}
+	return e
+}
 
-	return nil
+func (e *enum) valid(value string) bool {
+	return value == "" || e.values[value]
 }
 
-// MarshallJSON overrides to enable json decoding.
-func (e *Enum) MarshallJSON() (data []byte, err error) {
-	keys := []string{}
-	for _, i := range e.data {
-		keys = append(keys, e.BasisKeys[i])
+func (e *enum) marshal(value string) ([]byte, error) {
+	if !e.valid(value) {
+		return nil, &EnumError{Type: e.name, Value: value}
 	}
+	return json.Marshal(value)
+}
 
-	if data, err = json.Marshal(keys); err != nil {
-		return nil, err
+func (e *enum) unmarshal(data []byte, value *string) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if !e.valid(v) {
+		return &EnumError{Type: e.name, Value: v}
 	}
+	*value = v
+	return nil
+}
+
+// InputType indicates how the user sent a raw input.
+type InputType string
+
+const (
+	// UnspecifiedInputType is an unspecified input.
+	UnspecifiedInputType InputType = "UNSPECIFIED_INPUT_TYPE"
+
+	// TouchInput is a touch or tap.
+	TouchInput InputType = "TOUCH"
+
+	// VoiceInput is spoken.
+	VoiceInput InputType = "VOICE"
+
+	// KeyboardInput is typed.
+	KeyboardInput InputType = "KEYBOARD"
+
+	// URLInput is triggered by a link.
+	URLInput InputType = "URL"
+)
+
+var inputTypes = newEnum("InputType",
+	string(UnspecifiedInputType),
+	string(TouchInput),
+	string(VoiceInput),
+	string(KeyboardInput),
+	string(URLInput),
+)
+
+// Valid reports whether t is a documented InputType.
+func (t InputType) Valid() bool {
+	return inputTypes.valid(string(t))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t InputType) MarshalJSON() ([]byte, error) {
+	return inputTypes.marshal(string(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *InputType) UnmarshalJSON(data []byte) error {
+	return inputTypes.unmarshal(data, (*string)(t))
+}
+
+// Permission is a permission the user has granted to the Action.
+type Permission string
+
+const (
+	// UnspecifiedPermission is an unspecified permission.
+	UnspecifiedPermission Permission = "UNSPECIFIED_PERMISSION"
+
+	// NamePermission grants the user's full name.
+	NamePermission Permission = "NAME"
+
+	// DevicePreciseLocationPermission grants the device's precise location.
+	DevicePreciseLocationPermission Permission = "DEVICE_PRECISE_LOCATION"
+
+	// DeviceCoarseLocationPermission grants the device's zip code and city.
+	DeviceCoarseLocationPermission Permission = "DEVICE_COARSE_LOCATION"
+
+	// UpdatePermission grants sending updates to the user.
+	UpdatePermission Permission = "UPDATE"
+)
+
+var permissions = newEnum("Permission",
+	string(UnspecifiedPermission),
+	string(NamePermission),
+	string(DevicePreciseLocationPermission),
+	string(DeviceCoarseLocationPermission),
+	string(UpdatePermission),
+)
+
+// Valid reports whether p is a documented Permission.
+func (p Permission) Valid() bool {
+	return permissions.valid(string(p))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p Permission) MarshalJSON() ([]byte, error) {
+	return permissions.marshal(string(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *Permission) UnmarshalJSON(data []byte) error {
+	return permissions.unmarshal(data, (*string)(p))
+}
+
+// SKUType is the type of a package entitlement.
+type SKUType string
+
+const (
+	// UnspecifiedSKUType is an unspecified SKU type.
+	UnspecifiedSKUType SKUType = "SKU_TYPE_UNSPECIFIED"
+
+	// InAppSKU is an in-app purchase.
+	InAppSKU SKUType = "IN_APP"
+
+	// SubscriptionSKU is a subscription.
+	SubscriptionSKU SKUType = "SUBSCRIPTION"
+
+	// AppSKU is a paid app.
+	AppSKU SKUType = "APP"
+)
+
+var skuTypes = newEnum("SKUType",
+	string(UnspecifiedSKUType),
+	string(InAppSKU),
+	string(SubscriptionSKU),
+	string(AppSKU),
+)
+
+// Valid reports whether t is a documented SKUType.
+func (t SKUType) Valid() bool {
+	return skuTypes.valid(string(t))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t SKUType) MarshalJSON() ([]byte, error) {
+	return skuTypes.marshal(string(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *SKUType) UnmarshalJSON(data []byte) error {
+	return skuTypes.unmarshal(data, (*string)(t))
+}
+
+// HorizontalAlignment aligns the content of a table card column.
+type HorizontalAlignment string
+
+const (
+	// LeadingAlignment aligns content to the leading edge.
+	LeadingAlignment HorizontalAlignment = "LEADING"
+
+	// CenterAlignment centers content.
+	CenterAlignment HorizontalAlignment = "CENTER"
+
+	// TrailingAlignment aligns content to the trailing edge.
+	TrailingAlignment HorizontalAlignment = "TRAILING"
+)
+
+var horizontalAlignments = newEnum("HorizontalAlignment",
+	string(LeadingAlignment),
+	string(CenterAlignment),
+	string(TrailingAlignment),
+)
+
+// Valid reports whether a is a documented HorizontalAlignment.
+func (a HorizontalAlignment) Valid() bool {
+	return horizontalAlignments.valid(string(a))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a HorizontalAlignment) MarshalJSON() ([]byte, error) {
+	return horizontalAlignments.marshal(string(a))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *HorizontalAlignment) UnmarshalJSON(data []byte) error {
+	return horizontalAlignments.unmarshal(data, (*string)(a))
+}
+
+// ConversationType is the state of the conversation.
+type ConversationType string
+
+const (
+	// UnspecifiedConversationType is an unspecified conversation state.
+	UnspecifiedConversationType ConversationType = "TYPE_UNSPECIFIED"
+
+	// NewConversation is the first turn of a conversation.
+	NewConversation ConversationType = "NEW"
+
+	// ActiveConversation is a conversation in progress.
+	ActiveConversation ConversationType = "ACTIVE"
+)
+
+var conversationTypes = newEnum("ConversationType",
+	string(UnspecifiedConversationType),
+	string(NewConversation),
+	string(ActiveConversation),
+)
+
+// Valid reports whether t is a documented ConversationType.
+func (t ConversationType) Valid() bool {
+	return conversationTypes.valid(string(t))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t ConversationType) MarshalJSON() ([]byte, error) {
+	return conversationTypes.marshal(string(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *ConversationType) UnmarshalJSON(data []byte) error {
+	return conversationTypes.unmarshal(data, (*string)(t))
+}
+
+// SignInStatus is the result of a sign in request.
+type SignInStatus string
+
+const (
+	// UnspecifiedSignInStatus is an unspecified sign in result.
+	UnspecifiedSignInStatus SignInStatus = "SIGN_IN_STATUS_UNSPECIFIED"
+
+	// SignInOK means the user linked their account.
+	SignInOK SignInStatus = "OK"
+
+	// SignInCancelled means the user declined to link their account.
+	SignInCancelled SignInStatus = "CANCELLED"
+
+	// SignInError means account linking failed.
+	SignInError SignInStatus = "ERROR"
+)
+
+var signInStatuses = newEnum("SignInStatus",
+	string(UnspecifiedSignInStatus),
+	string(SignInOK),
+	string(SignInCancelled),
+	string(SignInError),
+)
+
+// Valid reports whether s is a documented SignInStatus.
+func (s SignInStatus) Valid() bool {
+	return signInStatuses.valid(string(s))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s SignInStatus) MarshalJSON() ([]byte, error) {
+	return signInStatuses.marshal(string(s))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *SignInStatus) UnmarshalJSON(data []byte) error {
+	return signInStatuses.unmarshal(data, (*string)(s))
+}
+
+// NewSurfaceStatus is the result of a request to move to a new surface.
+type NewSurfaceStatus string
+
+const (
+	// UnspecifiedNewSurfaceStatus is an unspecified new surface result.
+	UnspecifiedNewSurfaceStatus NewSurfaceStatus = "NEW_SURFACE_STATUS_UNSPECIFIED"
+
+	// NewSurfaceCancelled means the user declined the new surface.
+	NewSurfaceCancelled NewSurfaceStatus = "CANCELLED"
+
+	// NewSurfaceOK means the conversation moved to the new surface.
+	NewSurfaceOK NewSurfaceStatus = "OK"
+)
+
+var newSurfaceStatuses = newEnum("NewSurfaceStatus",
+	string(UnspecifiedNewSurfaceStatus),
+	string(NewSurfaceCancelled),
+	string(NewSurfaceOK),
+)
+
+// Valid reports whether s is a documented NewSurfaceStatus.
+func (s NewSurfaceStatus) Valid() bool {
+	return newSurfaceStatuses.valid(string(s))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s NewSurfaceStatus) MarshalJSON() ([]byte, error) {
+	return newSurfaceStatuses.marshal(string(s))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *NewSurfaceStatus) UnmarshalJSON(data []byte) error {
+	return newSurfaceStatuses.unmarshal(data, (*string)(s))
+}
+
+// PlaybackStatus is the playback state of a media response.
+type PlaybackStatus string
+
+const (
+	// UnspecifiedMediaStatus is an unspecified playback state.
+	UnspecifiedMediaStatus PlaybackStatus = "STATUS_UNSPECIFIED"
+
+	// MediaFinished means playback finished.
+	MediaFinished PlaybackStatus = "FINISHED"
+)
+
+var mediaStatuses = newEnum("PlaybackStatus",
+	string(UnspecifiedMediaStatus),
+	string(MediaFinished),
+)
+
+// Valid reports whether s is a documented PlaybackStatus.
+func (s PlaybackStatus) Valid() bool {
+	return mediaStatuses.valid(string(s))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s PlaybackStatus) MarshalJSON() ([]byte, error) {
+	return mediaStatuses.marshal(string(s))
+}
 
-	return data, nil
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *PlaybackStatus) UnmarshalJSON(data []byte) error {
+	return mediaStatuses.unmarshal(data, (*string)(s))
 }
@@ -0,0 +1,37 @@
+package google
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEnumRoundTrip(t *testing.T) {
+	want := &User{
+		Permissions: []Permission{NamePermission, UpdatePermission},
+	}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got *User
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want.Permissions, got.Permissions) {
+		t.Errorf("want: %v, got: %v", want.Permissions, got.Permissions)
+	}
+}
+
+func TestEnumUnknownValue(t *testing.T) {
+	var raw *RawInput
+	err := json.Unmarshal([]byte(`{"inputType": "TELEPATHY"}`), &raw)
+	if _, ok := err.(*EnumError); !ok {
+		t.Errorf("want: *EnumError, got: %v", err)
+	}
+
+	_, err = json.Marshal(&ColumnProperty{HorizontalAlignment: "DIAGONAL"})
+	if err == nil {
+		t.Error("want error marshaling unknown HorizontalAlignment")
+	}
+}
@@ -1,46 +1,77 @@
 package google
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Input represents the input data payload.
 type Input struct {
-	RawInputs []*RawInput
-	Intent    string
-	Arguments []*Argument
+	RawInputs []*RawInput `json:"rawInputs,omitempty"`
+	Intent    string      `json:"intent,omitempty"`
+	Arguments []*Argument `json:"arguments,omitempty"`
 }
 
 // RawInput transcription from each turn of conversation.
 type RawInput struct {
-	InputType string //Todo make enum type
-	Query     string
-	URL       string
+	InputType InputType `json:"inputType,omitempty"`
+	Query     string    `json:"query,omitempty"`
+	URL       string    `json:"url,omitempty"`
 }
 
 // Argument list of provided argument values for the input requested by the Action.
 type Argument struct {
-	Name          string
-	RawText       string
-	TextValue     string
-	Status        json.RawMessage
-	IntValue      string
-	FloatValue    float64
-	BoolValue     bool
-	DatetimeValue struct {
-		Date struct {
-			Year  int
-			Month int
-			Day   int
-		}
-		Time struct {
-			Hours   int
-			Minutes int
-			Seconds int
-			Nanos   int
-		}
+	Name            string          `json:"name,omitempty"`
+	RawText         string          `json:"rawText,omitempty"`
+	TextValue       string          `json:"textValue,omitempty"`
+	Status          json.RawMessage `json:"status,omitempty"`
+	IntValue        string          `json:"intValue,omitempty"`
+	FloatValue      float64         `json:"floatValue,omitempty"`
+	BoolValue       bool            `json:"boolValue,omitempty"`
+	DatetimeValue   DateTime        `json:"datetimeValue"`
+	PlaceValue      *Location       `json:"placeValue,omitempty"`
+	Extension       json.RawMessage `json:"extension,omitempty"`
+	StructuredValue json.RawMessage `json:"structuredValue,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. It omits an unset DatetimeValue.
+func (a Argument) MarshalJSON() ([]byte, error) {
+	type argument Argument
+	v := struct {
+		argument
+		DatetimeValue *DateTime `json:"datetimeValue,omitempty"`
+	}{argument: argument(a)}
+	if a.DatetimeValue != (DateTime{}) {
+		v.DatetimeValue = &a.DatetimeValue
 	}
-	PlaceValue      *Location
-	Extension       json.RawMessage
-	StructuredValue json.RawMessage
+	return json.Marshal(v)
+}
+
+// DateTime is the date and time value of an argument.
+type DateTime struct {
+	Date Date      `json:"date"`
+	Time TimeOfDay `json:"time"`
+}
+
+// Date is a calendar date.
+type Date struct {
+	Year  int `json:"year,omitempty"`
+	Month int `json:"month,omitempty"`
+	Day   int `json:"day,omitempty"`
+}
+
+// TimeOfDay is a time of day.
+type TimeOfDay struct {
+	Hours   int `json:"hours,omitempty"`
+	Minutes int `json:"minutes,omitempty"`
+	Seconds int `json:"seconds,omitempty"`
+	Nanos   int `json:"nanos,omitempty"`
+}
+
+// In returns the date and time as a time.Time in loc.
+func (d DateTime) In(loc *time.Location) time.Time {
+	return time.Date(d.Date.Year, time.Month(d.Date.Month), d.Date.Day,
+		d.Time.Hours, d.Time.Minutes, d.Time.Seconds, d.Time.Nanos, loc)
 }
 
 // ExpectedInput the Action expects.
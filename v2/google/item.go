@@ -66,8 +66,8 @@ type TableCard struct {
 
 // ColumnProperty specifies column in table card.
 type ColumnProperty struct {
-	Header              string `json:"header,omitempty"`
-	HorizontalAlignment string `json:"horizontalAlignment,omitempty"` // Todo: make enum type
+	Header              string              `json:"header,omitempty"`
+	HorizontalAlignment HorizontalAlignment `json:"horizontalAlignment,omitempty"`
 }
 
 // Row defines the row in a table card.
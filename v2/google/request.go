@@ -2,11 +2,11 @@ package google
 
 // Request is the HTTP request body from Google Assistant Actions.
 type Request struct {
-	User              *User
-	Device            *Device
-	Surface           *Surface
-	Conversation      *Conversation
-	Inputs            []*Input
-	IsInSandbox       bool
-	AvailableSurfaces []*Surface
+	User              *User         `json:"user,omitempty"`
+	Device            *Device       `json:"device,omitempty"`
+	Surface           *Surface      `json:"surface,omitempty"`
+	Conversation      *Conversation `json:"conversation,omitempty"`
+	Inputs            []*Input      `json:"inputs,omitempty"`
+	IsInSandbox       bool          `json:"isInSandbox,omitempty"`
+	AvailableSurfaces []*Surface    `json:"availableSurfaces,omitempty"`
 }
@@ -3,7 +3,10 @@ package google
 // Surface represents information specific to the Google Assistant client surface the user is interacting with.
 // Surface is distinguished from Device by the fact that multiple Assistant surfaces may live on the same device.
 type Surface struct {
-	Capabilities []struct {
-		Name string
-	}
+	Capabilities []Capability `json:"capabilities,omitempty"`
+}
+
+// Capability is a capability of a surface, e.g. actions.capability.SCREEN_OUTPUT.
+type Capability struct {
+	Name string `json:"name"`
 }
@@ -2,31 +2,31 @@ package google
 
 // User represents user data in Google payload of request.
 type User struct {
-	UserID              string
-	IDToken             string
-	Profile             *UserProfile
-	AccessToken         string
-	Permissions         []string // Todo: make enum type.
-	Locale              string
-	LastSeen            string
-	UserStorage         string
-	PackageEntitlements []*PackageEntitlement
+	UserID              string                `json:"userId,omitempty"`
+	IDToken             string                `json:"idToken,omitempty"`
+	Profile             *UserProfile          `json:"profile,omitempty"`
+	AccessToken         string                `json:"accessToken,omitempty"`
+	Permissions         []Permission          `json:"permissions,omitempty"`
+	Locale              string                `json:"locale,omitempty"`
+	LastSeen            string                `json:"lastSeen,omitempty"`
+	UserStorage         string                `json:"userStorage,omitempty"`
+	PackageEntitlements []*PackageEntitlement `json:"packageEntitlements,omitempty"`
 }
 
 // UserProfile represents user name information.
 type UserProfile struct {
-	DisplayName string
-	GivenName   string
-	FamilyName  string
+	DisplayName string `json:"displayName,omitempty"`
+	GivenName   string `json:"givenName,omitempty"`
+	FamilyName  string `json:"familyName,omitempty"`
 }
 
 // PackageEntitlement represents list of entitlements related to a package name.
 type PackageEntitlement struct {
-	PackageName  string
+	PackageName  string `json:"packageName,omitempty"`
 	Entitlements []struct {
-		SKU          string
-		SKUType      string // Todo: make enum type
+		SKU          string  `json:"sku,omitempty"`
+		SKUType      SKUType `json:"skuType,omitempty"`
 		InAppDetails struct {
-		}
-	}
+		} `json:"inAppDetails"`
+	} `json:"entitlements,omitempty"`
 }
@@ -0,0 +1,82 @@
+package identity
+
+import (
+	"context"
+	"errors"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/fulfillment"
+)
+
+// DefaultSigninPrompt is said when RequireAccount asks the user to link their account.
+const DefaultSigninPrompt = "To get your account details"
+
+type contextKey int
+
+const (
+	accountKey contextKey = iota
+)
+
+// LookupFunc resolves the access token of a fulfillment request to the token issued for it.
+// Handler.Introspect is a LookupFunc.
+type LookupFunc func(ctx context.Context, accessToken string) (*Token, error)
+
+// NewTokenContext returns a copy of ctx carrying the linked account's token.
+func NewTokenContext(ctx context.Context, t *Token) context.Context {
+	return context.WithValue(ctx, accountKey, t)
+}
+
+// TokenFromContext returns the linked account's token in ctx, if any.
+func TokenFromContext(ctx context.Context) (*Token, bool) {
+	t, ok := ctx.Value(accountKey).(*Token)
+	return t, ok
+}
+
+// ResolveAccount resolves the request's google.User.AccessToken, when present, with lookup
+// and puts the linked account's token in the handler's context. Unknown and expired
+// tokens leave the account unlinked; other lookup errors fail the request.
+func ResolveAccount(lookup LookupFunc) fulfillment.Middleware {
+	return func(next fulfillment.Handler) fulfillment.Handler {
+		return fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+			token := accessToken(req)
+			if token == "" {
+				return next.Fulfill(ctx, req)
+			}
+			t, err := lookup(ctx, token)
+			switch {
+			case err == nil:
+				ctx = NewTokenContext(ctx, t)
+			case errors.Is(err, ErrTokenNotFound), errors.Is(err, ErrExpiredToken):
+			default:
+				return nil, err
+			}
+			return next.Fulfill(ctx, req)
+		})
+	}
+}
+
+// RequireAccount protects a handler, asking the user to link their account with a
+// v2.Signin prompt saying say when ResolveAccount found no linked account.
+// An empty say uses DefaultSigninPrompt.
+func RequireAccount(say string) fulfillment.Middleware {
+	if say == "" {
+		say = DefaultSigninPrompt
+	}
+	return func(next fulfillment.Handler) fulfillment.Handler {
+		return fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+			if _, ok := TokenFromContext(ctx); !ok {
+				return &v2.Signin{RequiredResponse: say}, nil
+			}
+			return next.Fulfill(ctx, req)
+		})
+	}
+}
+
+func accessToken(req *dialogflow.Request) string {
+	payload := req.OriginalDetectIntentRequest.Payload
+	if payload == nil || payload.User == nil {
+		return ""
+	}
+	return payload.User.AccessToken
+}
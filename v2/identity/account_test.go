@@ -0,0 +1,93 @@
+package identity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/fulfillment"
+	"github.com/damondouglas/go.actions/v2/google"
+)
+
+func linkedRequest(accessToken string) *dialogflow.Request {
+	req := &dialogflow.Request{}
+	req.OriginalDetectIntentRequest.Payload = &google.Request{
+		User: &google.User{AccessToken: accessToken},
+	}
+	return req
+}
+
+// whoami says the subject of the linked account.
+var whoami = fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+	t, _ := TokenFromContext(ctx)
+	return &v2.Simple{Say: t.Subject}, nil
+})
+
+func systemIntent(t *testing.T, enc v2.Encoder) string {
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var resp *dialogflow.Response
+	if err := json.Unmarshal(buf.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Payload.Google.SystemIntent == nil {
+		return ""
+	}
+	return resp.Payload.Google.SystemIntent.Intent
+}
+
+func TestRequireAccount(t *testing.T) {
+	store := NewMemoryTokenStore()
+	store.Save(context.Background(), testToken("access", "refresh", "alice"))
+	h := fulfillment.Chain(whoami, ResolveAccount(store.Access), RequireAccount(""))
+
+	enc, err := h.Fulfill(context.Background(), linkedRequest("access"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := enc.(*v2.Simple).Say; got != "alice" {
+		t.Errorf("want: alice, got: %v", got)
+	}
+
+	for _, token := range []string{"", "unknown"} {
+		enc, err := h.Fulfill(context.Background(), linkedRequest(token))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := systemIntent(t, enc), "actions.intent.SIGN_IN"; got != want {
+			t.Errorf("%q: want: %v, got: %v", token, want, got)
+		}
+	}
+}
+
+func TestResolveAccountLookupError(t *testing.T) {
+	want := errors.New("store unavailable")
+	lookup := func(ctx context.Context, token string) (*Token, error) {
+		return nil, want
+	}
+	h := fulfillment.Chain(whoami, ResolveAccount(lookup))
+	if _, err := h.Fulfill(context.Background(), linkedRequest("access")); err != want {
+		t.Errorf("want: %v, got: %v", want, err)
+	}
+}
+
+func TestResolveAccountWrappedNotFound(t *testing.T) {
+	lookup := func(ctx context.Context, token string) (*Token, error) {
+		return nil, fmt.Errorf("datastore: %w", ErrTokenNotFound)
+	}
+	h := fulfillment.Chain(whoami, ResolveAccount(lookup), RequireAccount(""))
+	enc, err := h.Fulfill(context.Background(), linkedRequest("access"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := systemIntent(t, enc), "actions.intent.SIGN_IN"; got != want {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
@@ -0,0 +1,105 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Code is an authorization code issued to a client on behalf of a user.
+type Code struct {
+	Code        string    `json:"code"`
+	Subject     string    `json:"sub"`
+	ClientID    string    `json:"clientId"`
+	RedirectURI string    `json:"redirectUri,omitempty"`
+	Scopes      []string  `json:"scopes,omitempty"`
+	Expiry      time.Time `json:"expiry"`
+
+	// CodeChallenge and CodeChallengeMethod are the client's PKCE challenge, if any.
+	CodeChallenge       string `json:"codeChallenge,omitempty"`
+	CodeChallengeMethod string `json:"codeChallengeMethod,omitempty"`
+
+	// Upstream is the upstream provider's token for the user.
+	Upstream *oauth2.Token `json:"upstream,omitempty"`
+}
+
+// Token is an access token, and its refresh token, issued to a client on behalf of a user.
+type Token struct {
+	AccessToken   string    `json:"accessToken"`
+	RefreshToken  string    `json:"refreshToken,omitempty"`
+	Subject       string    `json:"sub"`
+	ClientID      string    `json:"clientId"`
+	Scopes        []string  `json:"scopes,omitempty"`
+	Expiry        time.Time `json:"expiry"`
+	RefreshExpiry time.Time `json:"refreshExpiry,omitempty"`
+
+	// Upstream is the upstream provider's token for the user, to call its APIs on their behalf.
+	Upstream *oauth2.Token `json:"upstream,omitempty"`
+}
+
+// Active reports whether the access token is unexpired at now.
+func (t *Token) Active(now time.Time) bool {
+	return now.Before(t.Expiry)
+}
+
+// Refreshable reports whether the refresh token is unexpired at now.
+func (t *Token) Refreshable(now time.Time) bool {
+	return t.RefreshToken != "" && (t.RefreshExpiry.IsZero() || now.Before(t.RefreshExpiry))
+}
+
+// ErrCodeNotFound is returned when an authorization code was never issued or was
+// already exchanged.
+var ErrCodeNotFound = errors.New("identity: code not found")
+
+// CodeStore keeps issued authorization codes until they are exchanged. Instances that
+// share a CodeStore can exchange each other's codes.
+type CodeStore interface {
+	// Save stores c, keyed by its code.
+	Save(ctx context.Context, c *Code) error
+
+	// Take returns and removes the code, so it can only be exchanged once, or returns
+	// ErrCodeNotFound.
+	Take(ctx context.Context, code string) (*Code, error)
+}
+
+// MemoryCodeStore keeps codes in memory. It suits a single instance.
+type MemoryCodeStore struct {
+	mu    sync.Mutex
+	codes map[string]*Code
+}
+
+// NewMemoryCodeStore returns an empty MemoryCodeStore.
+func NewMemoryCodeStore() *MemoryCodeStore {
+	return &MemoryCodeStore{
+		codes: map[string]*Code{},
+	}
+}
+
+// Save implements CodeStore.
+func (s *MemoryCodeStore) Save(ctx context.Context, c *Code) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, v := range s.codes {
+		if now.After(v.Expiry) {
+			delete(s.codes, k)
+		}
+	}
+	s.codes[c.Code] = c
+	return nil
+}
+
+// Take implements CodeStore.
+func (s *MemoryCodeStore) Take(ctx context.Context, code string) (*Code, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.codes[code]
+	if !ok {
+		return nil, ErrCodeNotFound
+	}
+	delete(s.codes, code)
+	return c, nil
+}
@@ -2,190 +2,340 @@ package identity
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"html/template"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
-	"os"
-	"strings"
-
-	"google.golang.org/appengine"
+	"time"
 
+	"github.com/damondouglas/go.actions/v2/auth"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
 const (
 	actionsRedirectBase = "https://oauth-redirect.googleusercontent.com/r/"
-	projectIDKey        = "PROJECT_ID"
-	secretPathKey       = "SECRET_PATH"
-	testHostKey         = "TEST_HOST"
-	scopesKey           = "SCOPES"
 
-	accessTokenKey = "access_token"
 	stateKey       = "state"
 	redirectURIKey = "redirect_uri"
 	codeKey        = "code"
-	tokenTypeKey   = "token_type"
 	bearerKey      = "bearer"
 
+	forwardedProtoKey = "X-Forwarded-Proto"
+
 	// AuthPath is the path to the first authorization flow.
 	AuthPath = "auth"
+
+	// ExchangePath is the path the upstream provider redirects to with its code.
+	ExchangePath = "exch"
 )
 
-// StoreHandler handles token storage.
-type StoreHandler func(context.Context, *oauth2.Token)
+// BaseURLFunc resolves the external base URL of the service handling r, without a trailing slash.
+type BaseURLFunc func(r *http.Request) string
 
-var (
-	app         *appConfig
-	oauthConfig *oauth2.Config
-)
+// Config configures account linking.
+type Config struct {
+	// ProjectID is the Actions project ID, used to redirect back to Google.
+	ProjectID string
 
-func init() {
-	var err error
-	app, err = loadYamlConfig()
-	if err != nil {
-		log.Fatal(err)
+	// OAuth2 configures the upstream provider: see GoogleConfig, Provider.Config, or build one
+	// directly, e.g. with golang.org/x/oauth2/github. Its RedirectURL is set per request
+	// from BaseURL.
+	OAuth2 *oauth2.Config
+
+	// BaseURL resolves the external base URL of the service. Defaults to RequestBaseURL.
+	BaseURL BaseURLFunc
+
+	// Clients are the OAuth clients allowed to link accounts, such as Google.
+	Clients []*Client
+
+	// Subject identifies the user from the upstream provider's token. Defaults to IDTokenSubject.
+	Subject SubjectFunc
+
+	// CodeTTL is how long authorization codes may be exchanged. Defaults to DefaultCodeTTL.
+	CodeTTL time.Duration
+
+	// AccessTokenTTL is how long access tokens are valid. Defaults to DefaultAccessTokenTTL.
+	AccessTokenTTL time.Duration
+
+	// RefreshTokenTTL is how long refresh tokens are valid. Zero means they do not expire.
+	RefreshTokenTTL time.Duration
+
+	// Tokens keeps the tokens issued to clients. Defaults to a MemoryTokenStore.
+	Tokens TokenStore
+
+	// Codes keeps authorization codes until they are exchanged. Defaults to a
+	// MemoryCodeStore; instances behind a load balancer must share one.
+	Codes CodeStore
+
+	// IDTokens verifies the Google ID tokens of the jwt-bearer grant, for Google Sign-In
+	// linking. The grant is unsupported unless IDTokens and Account are set.
+	IDTokens *auth.IDTokenVerifier
+
+	// Account finds or creates the account of a user signed in to Google.
+	Account AccountFunc
+
+	// Login authenticates our own users by username and password. When set, AuthHandler
+	// shows the login page instead of sending users to the upstream provider, and OAuth2
+	// may be nil.
+	Login LoginFunc
+
+	// Consent shows the consent page, listing the requested scopes, before issuing codes.
+	Consent bool
+
+	// Consents keeps flows waiting on the consent page. Defaults to a
+	// MemoryConsentStore; instances behind a load balancer must share one.
+	Consents ConsentStore
+
+	// Templates overrides the LoginTemplate and ConsentTemplate pages, executed with a
+	// *Page. Defaults to DefaultTemplates.
+	Templates *template.Template
+
+	// States keeps each flow's state while the user is at the upstream provider.
+	// Defaults to a MemoryStateStore. New calls its Validate method, if it has one,
+	// such as CookieStateStore's.
+	States StateStore
+
+	// StateTTL limits how long a flow may take. Defaults to DefaultStateTTL.
+	StateTTL time.Duration
+}
+
+// StaticBaseURL always resolves to u, e.g. a tunnel while testing locally.
+func StaticBaseURL(u string) BaseURLFunc {
+	return func(r *http.Request) string {
+		return u
 	}
+}
 
-	oauthConfig, err = loadOauthConfig()
-	if err != nil {
-		log.Fatal(err)
+// RequestBaseURL resolves the base URL from the request's host, honoring X-Forwarded-Proto
+// from proxies such as Cloud Run.
+func RequestBaseURL(r *http.Request) string {
+	scheme := "https"
+	if r.TLS == nil {
+		scheme = "http"
 	}
+	if proto := r.Header.Get(forwardedProtoKey); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
 }
 
-func logError(err error) {
+// GoogleConfig loads a Google client secret JSON file downloaded from the Cloud Console.
+func GoogleConfig(path string, scopes ...string) (*oauth2.Config, error) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	return google.ConfigFromJSON(data, scopes...)
 }
 
-type appConfig struct {
-	ProjectID  string
-	SecretPath string
-	TestHost   string
-	Scopes     []string
+// Handler handles account linking requests.
+type Handler struct {
+	config *Config
 }
 
-func loadYamlConfig() (*appConfig, error) {
-	yamlMap := map[string]string{
-		projectIDKey:  os.Getenv(projectIDKey),
-		secretPathKey: os.Getenv(secretPathKey),
-		testHostKey:   os.Getenv(testHostKey),
-		scopesKey:     os.Getenv(scopesKey),
+// New returns a Handler for cfg.
+func New(cfg *Config) (*Handler, error) {
+	if cfg.ProjectID == "" {
+		return nil, errors.New("identity: ProjectID is not set")
 	}
-
-	for key, value := range yamlMap {
-		if value == "" {
-			return nil, fmt.Errorf("%s is not set in app.yaml", key)
+	if cfg.OAuth2 == nil && cfg.Login == nil {
+		return nil, errors.New("identity: neither OAuth2 nor Login is set")
+	}
+	if len(cfg.Clients) == 0 {
+		return nil, errors.New("identity: Clients is not set")
+	}
+	c := *cfg
+	if c.BaseURL == nil {
+		c.BaseURL = RequestBaseURL
+	}
+	if c.States == nil {
+		c.States = NewMemoryStateStore()
+	}
+	if v, ok := c.States.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
 		}
 	}
-
-	scopes := strings.Split(yamlMap[scopesKey], ",")
-	for i := range scopes {
-		scopes[i] = strings.Trim(scopes[i], "")
+	if c.StateTTL == 0 {
+		c.StateTTL = DefaultStateTTL
 	}
-
-	return &appConfig{
-		ProjectID:  yamlMap[projectIDKey],
-		SecretPath: yamlMap[secretPathKey],
-		TestHost:   yamlMap[testHostKey],
-		Scopes:     scopes,
-	}, nil
-}
-
-func loadOauthConfig() (config *oauth2.Config, err error) {
-	data, err := ioutil.ReadFile(app.SecretPath)
-	if err != nil {
-		return nil, err
+	if c.Subject == nil {
+		c.Subject = IDTokenSubject
 	}
-	return google.ConfigFromJSON(data, app.Scopes...)
+	if c.CodeTTL == 0 {
+		c.CodeTTL = DefaultCodeTTL
+	}
+	if c.AccessTokenTTL == 0 {
+		c.AccessTokenTTL = DefaultAccessTokenTTL
+	}
+	if c.Tokens == nil {
+		c.Tokens = NewMemoryTokenStore()
+	}
+	if c.Codes == nil {
+		c.Codes = NewMemoryCodeStore()
+	}
+	if c.Consents == nil {
+		c.Consents = NewMemoryConsentStore()
+	}
+	if c.Templates == nil {
+		c.Templates = DefaultTemplates
+	}
+	return &Handler{
+		config: &c,
+	}, nil
 }
 
-func host(r *http.Request) string {
-	ctx := appengine.NewContext(r)
-	h := "https://" + appengine.DefaultVersionHostname(ctx)
-	if appengine.IsDevAppServer() {
-		h = app.TestHost
-	}
-	return h
+// ServeMux returns a mux serving the handler's endpoints under AuthPath, ExchangePath,
+// TokenPath, IntrospectPath, RevokePath, LoginPath and ConsentPath.
+func (h *Handler) ServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+AuthPath, h.AuthHandler)
+	mux.HandleFunc("/"+ExchangePath, h.TokenHandler)
+	mux.HandleFunc("/"+TokenPath, h.GrantHandler)
+	mux.HandleFunc("/"+IntrospectPath, h.IntrospectHandler)
+	mux.HandleFunc("/"+RevokePath, h.RevocationHandler)
+	mux.HandleFunc("/"+LoginPath, h.LoginHandler)
+	mux.HandleFunc("/"+ConsentPath, h.ConsentHandler)
+	return mux
 }
 
-// Handler handles exchange token requests.
-type Handler struct {
-	Store StoreHandler
-	State string
+func (h *Handler) oauthConfig(r *http.Request) *oauth2.Config {
+	c := *h.config.OAuth2
+	c.RedirectURL = h.config.BaseURL(r) + "/" + ExchangePath
+	return &c
 }
 
-// AuthHandler the first step in the OAuth2 flow.
-func AuthHandler(w http.ResponseWriter, r *http.Request) {
+// AuthHandler is the authorization endpoint, the first step in the OAuth2 flow. It sends
+// the user to the upstream provider, or shows the login page, to sign in. Requests from unknown clients or to
+// unregistered redirect URIs are refused; other errors are redirected to the client.
+func (h *Handler) AuthHandler(w http.ResponseWriter, r *http.Request) {
 	v := r.URL.Query()
+	client := h.client(v.Get(clientIDKey))
+	if client == nil {
+		http.Error(w, "identity: unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, ok := h.redirectURI(client, v.Get(redirectURIKey))
+	if !ok {
+		http.Error(w, "identity: redirect_uri is not registered", http.StatusBadRequest)
+		return
+	}
 	state := v.Get(stateKey)
-	redirectURI := host(r) + "/exch"
-	oauthConfig.RedirectURL = redirectURI
-	u := oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
-	log.Println("URL: ", u)
-	http.Redirect(w, r, u, http.StatusFound)
-}
-
-// TokenHandler handles token requests.
-func (h *Handler) TokenHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "GET" {
-		h.codeRequest(w, r)
+	if rt := v.Get(responseTypeKey); rt != responseTypeCode {
+		redirectError(w, r, redirectURI, state, &oauthError{Code: "unsupported_response_type", Description: rt})
+		return
+	}
+	challenge, method := v.Get(codeChallengeKey), v.Get(codeChallengeMethodKey)
+	if challenge != "" && method == "" {
+		method = PKCEMethodPlain
 	}
-	if r.Method == "POST" {
-		h.codePost(w, r)
+	if challenge != "" && !validPKCEMethod(method) {
+		redirectError(w, r, redirectURI, state, errInvalidRequest("unsupported code_challenge_method"))
+		return
 	}
+	verifier, err := newToken()
+	if err != nil {
+		redirectError(w, r, redirectURI, state, errServerError(r.Context(), err))
+		return
+	}
+	flow := &Flow{
+		State:               state,
+		RedirectURI:         redirectURI,
+		ClientID:            client.ID,
+		Scope:               v.Get(scopeKey),
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: method,
+		Verifier:            verifier,
+		Expiry:              time.Now().Add(h.config.StateTTL),
+	}
+	if h.config.Login != nil {
+		h.login(w, r, flow, "")
+		return
+	}
+	upstreamState, err := h.config.States.Save(w, r, flow)
+	if err != nil {
+		redirectError(w, r, redirectURI, state, errServerError(r.Context(), err))
+		return
+	}
+	opts := append([]oauth2.AuthCodeOption{oauth2.AccessTypeOffline, oauth2.ApprovalForce}, pkceAuthOptions(verifier)...)
+	http.Redirect(w, r, h.oauthConfig(r).AuthCodeURL(upstreamState, opts...), http.StatusFound)
 }
 
-func (h *Handler) codeRequest(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
-	v := r.URL.Query()
-	code := v.Get(codeKey)
-	state := v.Get(stateKey)
-	h.State = state
-	err := h.token(ctx, code, w, r)
-	if err != nil {
-		log.Fatal(err)
+// redirectURI resolves the redirect URI a client asked for against those it registered,
+// or Google's for the project if it registered none. An empty uri resolves to the only
+// registered one.
+func (h *Handler) redirectURI(c *Client, uri string) (string, bool) {
+	allowed := c.RedirectURIs
+	if len(allowed) == 0 {
+		allowed = []string{actionsRedirectBase + h.config.ProjectID}
+	}
+	if uri == "" && len(allowed) == 1 {
+		return allowed[0], true
 	}
+	for _, a := range allowed {
+		if uri == a {
+			return a, true
+		}
+	}
+	return "", false
 }
 
-func (h *Handler) codePost(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
-	data, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Fatal(err)
+// TokenHandler handles the upstream provider's callback and redirects back to the client
+// with an authorization code. The callback may be a form post if the StateStore's cookies
+// are sent cross-site, as with FormPost.
+func (h *Handler) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	if h.config.OAuth2 == nil {
+		http.NotFound(w, r)
+		return
 	}
-	v, err := url.ParseQuery(string(data))
-	if err != nil {
-		log.Fatal(err)
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
 	}
-	code := v.Get(codeKey)
-	err = h.token(ctx, code, w, r)
+	flow, err := h.config.States.Load(w, r, r.FormValue(stateKey))
 	if err != nil {
-		log.Fatal(err)
+		stateError(w, r, err)
+		return
+	}
+	if e := r.FormValue(errorKey); e != "" {
+		redirectError(w, r, flow.RedirectURI, flow.State, upstreamError(e, r.FormValue(errorDescriptionKey)))
+		return
+	}
+	if err := h.token(r.Context(), r.FormValue(codeKey), flow, w, r); err != nil {
+		redirectError(w, r, flow.RedirectURI, flow.State, errServerError(r.Context(), err))
 	}
 }
 
-func (h *Handler) token(ctx context.Context, code string, w http.ResponseWriter, r *http.Request) (err error) {
-	tok, err := oauthConfig.Exchange(ctx, code)
+func (h *Handler) token(ctx context.Context, code string, flow *Flow, w http.ResponseWriter, r *http.Request) (err error) {
+	tok, err := h.oauthConfig(r).Exchange(ctx, code, oauth2.SetAuthURLParam(codeVerifierKey, flow.Verifier))
 	if err != nil {
 		return err
 	}
-	if h.Store != nil {
-		h.Store(ctx, tok)
+	subject, err := h.config.Subject(ctx, tok)
+	if err != nil {
+		return err
 	}
-	h.redirect(tok, w, r)
-	return nil
+	return h.complete(w, r, subject, tok, flow)
 }
 
-func (h *Handler) redirect(tok *oauth2.Token, w http.ResponseWriter, r *http.Request) {
+func (h *Handler) redirect(c *Code, flow *Flow, w http.ResponseWriter, r *http.Request) {
 	qry, _ := url.ParseQuery("")
-	qry.Set(accessTokenKey, tok.AccessToken)
-	qry.Set(tokenTypeKey, bearerKey)
-	qry.Set(stateKey, h.State)
-	u := actionsRedirectBase + app.ProjectID + "#" + qry.Encode()
-	// u := "https://oauth-redirect.googleusercontent.com/r/" + app.ProjectID + "#" + "access_token=" + tok.AccessToken + "&token_type=bearer&state=" + h.State
-	http.Redirect(w, r, u, http.StatusFound)
+	qry.Set(codeKey, c.Code)
+	qry.Set(stateKey, flow.State)
+	http.Redirect(w, r, flow.RedirectURI+"?"+qry.Encode(), http.StatusFound)
+}
+
+// redirectError redirects an authorization error back to the client, as in RFC 6749 4.1.2.1.
+func redirectError(w http.ResponseWriter, r *http.Request, redirectURI, state string, e *oauthError) {
+	qry, _ := url.ParseQuery("")
+	qry.Set(errorKey, e.Code)
+	if e.Description != "" {
+		qry.Set(errorDescriptionKey, e.Description)
+	}
+	if state != "" {
+		qry.Set(stateKey, state)
+	}
+	http.Redirect(w, r, redirectURI+"?"+qry.Encode(), http.StatusFound)
 }
@@ -0,0 +1,111 @@
+package identity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	projectID    = "gallery-67d5b"
+	googleID     = "google"
+	googleSecret = "google-secret"
+)
+
+var googleRedirect = "https://oauth-redirect.googleusercontent.com/r/" + projectID
+
+func testConfig(provider string) *Config {
+	return &Config{
+		ProjectID: projectID,
+		OAuth2:    testOAuth2Config(provider),
+		Clients: []*Client{
+			{
+				ID:           googleID,
+				Secret:       googleSecret,
+				RedirectURIs: []string{googleRedirect},
+			},
+		},
+		Subject: func(ctx context.Context, tok *oauth2.Token) (string, error) {
+			return "user-" + tok.AccessToken, nil
+		},
+	}
+}
+
+// authURL is the URL Google opens to start linking.
+func authURL(state string) string {
+	v := url.Values{}
+	v.Set("client_id", googleID)
+	v.Set("redirect_uri", googleRedirect)
+	v.Set("response_type", "code")
+	v.Set("state", state)
+	return "https://example.com/auth?" + v.Encode()
+}
+
+func testOAuth2Config(provider string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  provider + "/authorize",
+			TokenURL: provider + "/token",
+		},
+		Scopes: []string{"email"},
+	}
+}
+
+func TestNewRequiresConfig(t *testing.T) {
+	for name, mutate := range map[string]func(*Config){
+		"ProjectID": func(c *Config) { c.ProjectID = "" },
+		"OAuth2":    func(c *Config) { c.OAuth2 = nil },
+		"Clients":   func(c *Config) { c.Clients = nil },
+	} {
+		cfg := testConfig("https://idp.example.com")
+		mutate(cfg)
+		if _, err := New(cfg); err == nil {
+			t.Errorf("want error without %s", name)
+		}
+	}
+}
+
+func TestAuthHandlerRedirect(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		baseURL BaseURLFunc
+		want    string
+	}{
+		{"request", nil, "https://example.com/exch"},
+		{"static", StaticBaseURL("https://dddev.ngrok.io"), "https://dddev.ngrok.io/exch"},
+	} {
+		cfg := testConfig("https://idp.example.com")
+		cfg.BaseURL = tt.baseURL
+		h, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", authURL("xyz"), nil)
+		r.Header.Set("X-Forwarded-Proto", "https")
+		h.ServeMux().ServeHTTP(w, r)
+		if w.Code != http.StatusFound {
+			t.Fatalf("%s: want: %v, got: %v", tt.name, http.StatusFound, w.Code)
+		}
+		u, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := u.Query().Get("redirect_uri"); got != tt.want {
+			t.Errorf("%s: want: %v, got: %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// takeCode returns and forgets an issued code.
+func takeCode(h *Handler, code string) (*Code, bool) {
+	c, err := h.config.Codes.Take(context.Background(), code)
+	return c, err == nil
+}
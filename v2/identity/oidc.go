@@ -0,0 +1,126 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+
+	// OpenIDScope requests an OpenID Connect ID token.
+	OpenIDScope = "openid"
+)
+
+// ErrIssuerMismatch is returned when a discovery document names another issuer.
+var ErrIssuerMismatch = errors.New("identity: discovery document issuer does not match")
+
+// Provider is an OpenID Connect provider's discovery document.
+type Provider struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI               string   `json:"jwks_uri,omitempty"`
+	ScopesSupported       []string `json:"scopes_supported,omitempty"`
+}
+
+// Discover fetches the discovery document of the OpenID Connect provider at issuer,
+// using the oauth2.HTTPClient in ctx if any. The document's issuer must equal issuer
+// exactly, including any trailing slash.
+func Discover(ctx context.Context, issuer string) (*Provider, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(issuer, "/")+discoveryPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient(ctx).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("identity: discovery of %s: %v", issuer, resp.Status)
+	}
+	p := &Provider{}
+	if err := json.NewDecoder(resp.Body).Decode(p); err != nil {
+		return nil, err
+	}
+	if p.Issuer != issuer {
+		return nil, ErrIssuerMismatch
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" {
+		return nil, fmt.Errorf("identity: discovery of %s: missing endpoints", issuer)
+	}
+	return p, nil
+}
+
+// Config returns an oauth2.Config for the provider, requesting OpenIDScope along
+// with scopes.
+func (p *Provider) Config(clientID, clientSecret string, scopes ...string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.AuthorizationEndpoint,
+			TokenURL: p.TokenEndpoint,
+		},
+		Scopes: append([]string{OpenIDScope}, scopes...),
+	}
+}
+
+// UserinfoSubject returns a SubjectFunc that identifies the user by calling endpoint
+// with the upstream token, for providers without ID tokens. It reads the sub field, or
+// the id field as GitHub's https://api.github.com/user returns.
+func UserinfoSubject(endpoint string) SubjectFunc {
+	return func(ctx context.Context, tok *oauth2.Token) (string, error) {
+		req, err := http.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Accept", "application/json")
+		tok.SetAuthHeader(req)
+		resp, err := httpClient(ctx).Do(req.WithContext(ctx))
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("identity: userinfo: %v", resp.Status)
+		}
+		var info struct {
+			Subject string      `json:"sub"`
+			ID      interface{} `json:"id"`
+		}
+		dec := json.NewDecoder(resp.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&info); err != nil {
+			return "", err
+		}
+		switch {
+		case info.Subject != "":
+			return info.Subject, nil
+		case info.ID != nil:
+			return fmt.Sprint(info.ID), nil
+		}
+		return "", ErrNoSubject
+	}
+}
+
+// UserinfoSubject returns a SubjectFunc calling the provider's userinfo endpoint.
+func (p *Provider) UserinfoSubject() SubjectFunc {
+	return UserinfoSubject(p.UserinfoEndpoint)
+}
+
+// httpClient returns the oauth2.HTTPClient in ctx, as golang.org/x/oauth2 does.
+func httpClient(ctx context.Context) *http.Client {
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok {
+		return c
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,130 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// newIdP serves a discovery document, token endpoint and userinfo endpoint, standing in
+// for a corporate identity provider.
+func newIdP() *httptest.Server {
+	mux := http.NewServeMux()
+	s := httptest.NewServer(mux)
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&Provider{
+			Issuer:                s.URL,
+			AuthorizationEndpoint: s.URL + "/authorize",
+			TokenEndpoint:         s.URL + "/token",
+			UserinfoEndpoint:      s.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "token-" + r.FormValue("code"),
+			"token_type":   "bearer",
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"id": 1234, "login": "octocat"}`))
+	})
+	return s
+}
+
+func TestDiscover(t *testing.T) {
+	idp := newIdP()
+	defer idp.Close()
+
+	p, err := Discover(context.Background(), idp.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := p.Config("client", "secret", "email")
+	if got, want := c.Endpoint.TokenURL, idp.URL+"/token"; got != want {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+	if got := c.Scopes; len(got) != 2 || got[0] != OpenIDScope {
+		t.Errorf("want: [openid email], got: %v", got)
+	}
+}
+
+func TestDiscoverTrailingSlash(t *testing.T) {
+	var issuer string
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != discoveryPath {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(&Provider{
+			Issuer:                issuer,
+			AuthorizationEndpoint: issuer + "authorize",
+			TokenEndpoint:         issuer + "oauth/token",
+		})
+	}))
+	defer idp.Close()
+	issuer = idp.URL + "/"
+
+	p, err := Discover(context.Background(), issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Issuer != issuer {
+		t.Errorf("want: %v, got: %v", issuer, p.Issuer)
+	}
+	if _, err := Discover(context.Background(), idp.URL); err != ErrIssuerMismatch {
+		t.Errorf("want: %v, got: %v", ErrIssuerMismatch, err)
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&Provider{
+			Issuer:                "https://idp.example.com",
+			AuthorizationEndpoint: "https://idp.example.com/authorize",
+			TokenEndpoint:         "https://idp.example.com/token",
+		})
+	}))
+	defer other.Close()
+
+	if _, err := Discover(context.Background(), other.URL); err != ErrIssuerMismatch {
+		t.Errorf("want: %v, got: %v", ErrIssuerMismatch, err)
+	}
+}
+
+func TestUserinfoSubject(t *testing.T) {
+	idp := newIdP()
+	defer idp.Close()
+
+	p, err := Discover(context.Background(), idp.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(idp.URL)
+	cfg.OAuth2 = p.Config("client", "secret")
+	cfg.Subject = p.UserinfoSubject()
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, ok := takeCode(h, linkedCode(t, h))
+	if !ok {
+		t.Fatal("code not issued")
+	}
+	if want := "1234"; c.Subject != want {
+		t.Errorf("want: %v, got: %v", want, c.Subject)
+	}
+
+	if _, err := cfg.Subject(context.Background(), &oauth2.Token{AccessToken: "forged"}); err == nil {
+		t.Error("want error for rejected token")
+	}
+}
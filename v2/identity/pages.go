@@ -0,0 +1,271 @@
+package identity
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// LoginPath is the path the login page posts to.
+	LoginPath = "login"
+
+	// ConsentPath is the path the consent page posts to.
+	ConsentPath = "consent"
+
+	// LoginTemplate and ConsentTemplate name the pages in Config.Templates.
+	LoginTemplate   = "login"
+	ConsentTemplate = "consent"
+
+	usernameKey = "username"
+	passwordKey = "password"
+	consentKey  = "consent"
+	allowKey    = "allow"
+)
+
+// ErrInvalidCredentials is returned by a LoginFunc when the username or password is wrong.
+var ErrInvalidCredentials = errors.New("identity: invalid username or password")
+
+// LoginFunc authenticates one of our own users and returns their subject.
+type LoginFunc func(ctx context.Context, username, password string) (subject string, err error)
+
+// Page is the data the login and consent templates are executed with.
+type Page struct {
+	// Action is the URL the page's form posts to.
+	Action string
+
+	// State is the hidden field identifying the flow, posted back as "state" from the
+	// login page and "consent" from the consent page.
+	State string
+
+	// ClientID is the client linking the account.
+	ClientID string
+
+	// Scopes are the scopes the client requested.
+	Scopes []string
+
+	// Error explains why the previous attempt failed, e.g. a wrong password.
+	Error string
+}
+
+// DefaultTemplates are the unbranded login and consent pages. The login form posts
+// state, username and password; the consent form posts consent and allow, or nothing
+// to cancel.
+var DefaultTemplates = template.Must(template.New(LoginTemplate).Parse(`<!DOCTYPE html>
+<html>
+<head><meta name="viewport" content="width=device-width, initial-scale=1"><title>Sign in</title></head>
+<body>
+<h1>Sign in to link your account</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="state" value="{{.State}}">
+<label>Username <input name="username" autocomplete="username" required></label>
+<label>Password <input name="password" type="password" autocomplete="current-password" required></label>
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+func init() {
+	template.Must(DefaultTemplates.New(ConsentTemplate).Parse(`<!DOCTYPE html>
+<html>
+<head><meta name="viewport" content="width=device-width, initial-scale=1"><title>Allow access</title></head>
+<body>
+<h1>Allow {{.ClientID}} to access your account?</h1>
+{{with .Scopes}}<ul>{{range .}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="consent" value="{{.State}}">
+<button type="submit" name="allow" value="true">Allow</button>
+<button type="submit">Cancel</button>
+</form>
+</body>
+</html>
+`))
+}
+
+// render executes the template name with p, replying only with status text, and logging
+// the error, if it fails.
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, name string, p *Page) {
+	var buf bytes.Buffer
+	if err := h.config.Templates.ExecuteTemplate(&buf, name, p); err != nil {
+		errServerError(r.Context(), err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	buf.WriteTo(w)
+}
+
+// stateError replies to a post whose flow could not be loaded: 403 for an invalid or
+// expired state, and a logged 500 for a failing StateStore.
+func stateError(w http.ResponseWriter, r *http.Request, err error) {
+	code := http.StatusForbidden
+	if !errors.Is(err, ErrInvalidState) && !errors.Is(err, ErrExpiredState) {
+		errServerError(r.Context(), err)
+		code = http.StatusInternalServerError
+	}
+	http.Error(w, http.StatusText(code), code)
+}
+
+// login saves flow and shows the login page.
+func (h *Handler) login(w http.ResponseWriter, r *http.Request, flow *Flow, message string) {
+	state, err := h.config.States.Save(w, r, flow)
+	if err != nil {
+		redirectError(w, r, flow.RedirectURI, flow.State, errServerError(r.Context(), err))
+		return
+	}
+	h.render(w, r, LoginTemplate, &Page{
+		Action:   h.config.BaseURL(r) + "/" + LoginPath,
+		State:    state,
+		ClientID: flow.ClientID,
+		Scopes:   strings.Fields(flow.Scope),
+		Error:    message,
+	})
+}
+
+// LoginHandler checks the credentials posted from the login page. A wrong password
+// shows the page again.
+func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || h.config.Login == nil {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	flow, err := h.config.States.Load(w, r, r.PostFormValue(stateKey))
+	if err != nil {
+		stateError(w, r, err)
+		return
+	}
+	subject, err := h.config.Login(r.Context(), r.PostFormValue(usernameKey), r.PostFormValue(passwordKey))
+	if errors.Is(err, ErrInvalidCredentials) {
+		h.login(w, r, flow, ErrInvalidCredentials.Error())
+		return
+	}
+	if err != nil {
+		redirectError(w, r, flow.RedirectURI, flow.State, errServerError(r.Context(), err))
+		return
+	}
+	if err := h.complete(w, r, subject, nil, flow); err != nil {
+		redirectError(w, r, flow.RedirectURI, flow.State, errServerError(r.Context(), err))
+	}
+}
+
+// complete finishes a flow once the user is known, asking for consent first if configured.
+func (h *Handler) complete(w http.ResponseWriter, r *http.Request, subject string, upstream *oauth2.Token, flow *Flow) error {
+	if h.config.Consent {
+		nonce, err := h.config.Consents.Save(r.Context(), &Consent{Subject: subject, Upstream: upstream, Flow: flow})
+		if err != nil {
+			return err
+		}
+		h.render(w, r, ConsentTemplate, &Page{
+			Action:   h.config.BaseURL(r) + "/" + ConsentPath,
+			State:    nonce,
+			ClientID: flow.ClientID,
+			Scopes:   strings.Fields(flow.Scope),
+		})
+		return nil
+	}
+	c, err := h.issueCode(r.Context(), subject, upstream, flow)
+	if err != nil {
+		return err
+	}
+	h.redirect(c, flow, w, r)
+	return nil
+}
+
+// ConsentHandler issues the code if the user allowed access on the consent page, and
+// redirects access_denied to the client otherwise.
+func (h *Handler) ConsentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	c, err := h.config.Consents.Take(r.Context(), r.PostFormValue(consentKey))
+	if err != nil {
+		http.Error(w, ErrInvalidState.Error(), http.StatusForbidden)
+		return
+	}
+	if r.PostFormValue(allowKey) != "true" {
+		redirectError(w, r, c.Flow.RedirectURI, c.Flow.State, &oauthError{Code: "access_denied"})
+		return
+	}
+	code, err := h.issueCode(r.Context(), c.Subject, c.Upstream, c.Flow)
+	if err != nil {
+		redirectError(w, r, c.Flow.RedirectURI, c.Flow.State, errServerError(r.Context(), err))
+		return
+	}
+	h.redirect(code, c.Flow, w, r)
+}
+
+// Consent is a flow waiting for the user to allow access on the consent page.
+type Consent struct {
+	Subject  string        `json:"sub"`
+	Upstream *oauth2.Token `json:"upstream,omitempty"`
+	Flow     *Flow         `json:"flow"`
+}
+
+// ConsentStore keeps flows waiting for consent until the consent page is posted.
+// Instances that share a ConsentStore can complete each other's flows.
+type ConsentStore interface {
+	// Save stores c and returns the opaque nonce the consent page posts back.
+	Save(ctx context.Context, c *Consent) (nonce string, err error)
+
+	// Take returns and removes the consent for nonce, or returns ErrInvalidState if there
+	// is none or ErrExpiredState if its flow expired.
+	Take(ctx context.Context, nonce string) (*Consent, error)
+}
+
+// MemoryConsentStore keeps consents in memory. It suits a single instance.
+type MemoryConsentStore struct {
+	mu       sync.Mutex
+	consents map[string]*Consent
+}
+
+// NewMemoryConsentStore returns an empty MemoryConsentStore.
+func NewMemoryConsentStore() *MemoryConsentStore {
+	return &MemoryConsentStore{
+		consents: map[string]*Consent{},
+	}
+}
+
+// Save implements ConsentStore.
+func (s *MemoryConsentStore) Save(ctx context.Context, c *Consent) (string, error) {
+	nonce, err := newNonce()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, v := range s.consents {
+		if now.After(v.Flow.Expiry) {
+			delete(s.consents, k)
+		}
+	}
+	s.consents[nonce] = c
+	return nonce, nil
+}
+
+// Take implements ConsentStore.
+func (s *MemoryConsentStore) Take(ctx context.Context, nonce string) (*Consent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.consents[nonce]
+	if !ok {
+		return nil, ErrInvalidState
+	}
+	delete(s.consents, nonce)
+	if time.Now().After(c.Flow.Expiry) {
+		return nil, ErrExpiredState
+	}
+	return c, nil
+}
@@ -0,0 +1,177 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var hiddenValue = regexp.MustCompile(`type="hidden" name="(?:state|consent)" value="([^"]*)"`)
+
+// hidden returns the flow's hidden field on a rendered page.
+func hidden(t *testing.T, w *httptest.ResponseRecorder) string {
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: %v, got: %v %s", http.StatusOK, w.Code, w.Body)
+	}
+	m := hiddenValue.FindStringSubmatch(w.Body.String())
+	if m == nil {
+		t.Fatalf("no hidden field in %s", w.Body)
+	}
+	return m[1]
+}
+
+func submit(h http.HandlerFunc, form url.Values, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "https://example.com/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func login(ctx context.Context, username, password string) (string, error) {
+	if username != "alice" || password != "secret" {
+		return "", fmt.Errorf("login %s: %w", username, ErrInvalidCredentials)
+	}
+	return "user-alice", nil
+}
+
+func TestLoginPage(t *testing.T) {
+	cfg := testConfig("")
+	cfg.OAuth2 = nil
+	cfg.Login = login
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := authorize(h, url.Values{scopeKey: {"profile"}})
+	w = submit(h.LoginHandler, url.Values{
+		stateKey:    {hidden(t, w)},
+		usernameKey: {"alice"},
+		passwordKey: {"wrong"},
+	}, w.Result().Cookies())
+	if !strings.Contains(w.Body.String(), ErrInvalidCredentials.Error()) {
+		t.Errorf("want error on page, got: %s", w.Body)
+	}
+
+	w = submit(h.LoginHandler, url.Values{
+		stateKey:    {hidden(t, w)},
+		usernameKey: {"alice"},
+		passwordKey: {"secret"},
+	}, w.Result().Cookies())
+	c, ok := takeCode(h, redirected(t, w).Get(codeKey))
+	if !ok {
+		t.Fatal("code not issued")
+	}
+	if c.Subject != "user-alice" {
+		t.Errorf("want: user-alice, got: %v", c.Subject)
+	}
+}
+
+func TestConsentPage(t *testing.T) {
+	provider := newProvider()
+	defer provider.Close()
+	cfg := testConfig(provider.URL)
+	cfg.Consent = true
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		allow string
+		want  string
+	}{
+		{"", "access_denied"},
+		{"true", ""},
+	} {
+		state, cookies := startFlow(t, h, "google")
+		page := finishFlow(h, "1", state, cookies)
+		w := submit(h.ConsentHandler, url.Values{
+			consentKey: {hidden(t, page)},
+			allowKey:   {tt.allow},
+		}, nil)
+		v := redirected(t, w)
+		if got := v.Get(errorKey); got != tt.want {
+			t.Errorf("allow %q: want: %q, got: %q", tt.allow, tt.want, got)
+		}
+		if got := v.Get(codeKey) != ""; got != (tt.want == "") {
+			t.Errorf("allow %q: want code: %v, got: %v", tt.allow, tt.want == "", got)
+		}
+	}
+
+	if w := submit(h.ConsentHandler, url.Values{consentKey: {"forged"}, allowKey: {"true"}}, nil); w.Code != http.StatusForbidden {
+		t.Errorf("forged: want: %v, got: %v", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestTemplatesOverride(t *testing.T) {
+	cfg := testConfig("")
+	cfg.Login = login
+	cfg.Templates = template.Must(template.New(LoginTemplate).Parse(`<h1>Example Corp</h1>{{.ClientID}}`))
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := authorize(h, nil)
+	if got, want := w.Body.String(), "<h1>Example Corp</h1>"+googleID; got != want {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+// failingStates is a StateStore whose backend is down.
+type failingStates struct{ StateStore }
+
+func (failingStates) Load(w http.ResponseWriter, r *http.Request, state string) (*Flow, error) {
+	return nil, fmt.Errorf("state backend at 10.0.0.1 is down")
+}
+
+func TestPageErrorsHidden(t *testing.T) {
+	cfg := testConfig("")
+	cfg.Login = login
+	cfg.Templates = template.Must(template.New(LoginTemplate).Parse(`<h1>Partial</h1>{{.Missing}}`))
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := authorize(h, nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("template: want: %v, got: %v", http.StatusInternalServerError, w.Code)
+	}
+	if body := w.Body.String(); strings.Contains(body, "Partial") || strings.Contains(body, "Missing") {
+		t.Errorf("template: want status text only, got: %s", body)
+	}
+
+	cfg = testConfig("")
+	cfg.Login = login
+	cfg.States = failingStates{NewMemoryStateStore()}
+	if h, err = New(cfg); err != nil {
+		t.Fatal(err)
+	}
+	w = submit(h.LoginHandler, url.Values{stateKey: {"state"}, usernameKey: {"user"}, passwordKey: {"password"}}, nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("state store: want: %v, got: %v", http.StatusInternalServerError, w.Code)
+	}
+	if body := w.Body.String(); strings.Contains(body, "10.0.0.1") {
+		t.Errorf("state store: want status text only, got: %s", body)
+	}
+
+	cfg.States = NewMemoryStateStore()
+	if h, err = New(cfg); err != nil {
+		t.Fatal(err)
+	}
+	w = submit(h.LoginHandler, url.Values{stateKey: {"forged"}}, nil)
+	if w.Code != http.StatusForbidden || strings.Contains(w.Body.String(), "identity:") {
+		t.Errorf("forged: want: %v with status text, got: %v %s", http.StatusForbidden, w.Code, w.Body)
+	}
+}
@@ -0,0 +1,55 @@
+package identity
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	codeChallengeKey       = "code_challenge"
+	codeChallengeMethodKey = "code_challenge_method"
+	codeVerifierKey        = "code_verifier"
+
+	// PKCEMethodS256 is the SHA-256 code challenge method of RFC 7636.
+	PKCEMethodS256 = "S256"
+
+	// PKCEMethodPlain is the plain code challenge method of RFC 7636.
+	PKCEMethodPlain = "plain"
+)
+
+// challengeS256 returns the S256 code challenge for verifier.
+func challengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// validPKCEMethod reports whether a client's code_challenge_method is supported.
+func validPKCEMethod(method string) bool {
+	return method == PKCEMethodS256 || method == PKCEMethodPlain
+}
+
+// verifyPKCE reports whether verifier answers challenge. A code issued without a
+// challenge needs no verifier.
+func verifyPKCE(challenge, method, verifier string) bool {
+	if challenge == "" {
+		return true
+	}
+	if verifier == "" {
+		return false
+	}
+	if method == PKCEMethodS256 {
+		verifier = challengeS256(verifier)
+	}
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(verifier)) == 1
+}
+
+// pkceAuthOptions adds an S256 code challenge for verifier to the upstream authorization URL.
+func pkceAuthOptions(verifier string) []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam(codeChallengeKey, challengeS256(verifier)),
+		oauth2.SetAuthURLParam(codeChallengeMethodKey, PKCEMethodS256),
+	}
+}
@@ -0,0 +1,160 @@
+package identity
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// authorize calls AuthHandler with Google's parameters, overridden by params.
+func authorize(h *Handler, params url.Values) *httptest.ResponseRecorder {
+	u, _ := url.Parse(authURL("google"))
+	v := u.Query()
+	for k := range params {
+		v.Set(k, params.Get(k))
+	}
+	u.RawQuery = v.Encode()
+	w := httptest.NewRecorder()
+	h.AuthHandler(w, httptest.NewRequest("GET", u.String(), nil))
+	return w
+}
+
+func TestAuthHandlerRedirectURI(t *testing.T) {
+	h, done := newTestHandler(t)
+	defer done()
+
+	for _, uri := range []string{
+		"https://oauth-redirect.googleusercontent.com/r/another-project",
+		"https://attacker.example.com/r/" + projectID,
+	} {
+		w := authorize(h, url.Values{redirectURIKey: {uri}})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: want: %v, got: %v", uri, http.StatusBadRequest, w.Code)
+		}
+		if loc := w.Header().Get("Location"); loc != "" {
+			t.Errorf("%s: want no redirect, got: %v", uri, loc)
+		}
+	}
+}
+
+func TestAuthHandlerErrorRedirect(t *testing.T) {
+	h, done := newTestHandler(t)
+	defer done()
+
+	for _, tt := range []struct {
+		params url.Values
+		want   string
+	}{
+		{url.Values{responseTypeKey: {"token"}}, "unsupported_response_type"},
+		{url.Values{codeChallengeKey: {"challenge"}, codeChallengeMethodKey: {"S512"}}, "invalid_request"},
+	} {
+		w := authorize(h, tt.params)
+		if w.Code != http.StatusFound {
+			t.Fatalf("want: %v, got: %v", http.StatusFound, w.Code)
+		}
+		u, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := u.Scheme + "://" + u.Host + u.Path; got != googleRedirect {
+			t.Errorf("want: %v, got: %v", googleRedirect, got)
+		}
+		if got := u.Query().Get(errorKey); got != tt.want {
+			t.Errorf("want: %v, got: %v", tt.want, got)
+		}
+		if got := u.Query().Get(stateKey); got != "google" {
+			t.Errorf("want: google, got: %v", got)
+		}
+	}
+}
+
+func TestUpstreamPKCE(t *testing.T) {
+	var verifier string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verifier = r.FormValue(codeVerifierKey)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "token-" + r.FormValue("code"),
+			"token_type":   "bearer",
+		})
+	}))
+	defer provider.Close()
+	h, err := New(testConfig(provider.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := authorize(h, nil)
+	u, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Query().Get(codeChallengeMethodKey); got != PKCEMethodS256 {
+		t.Errorf("want: %v, got: %v", PKCEMethodS256, got)
+	}
+	if w := finishFlow(h, "1", u.Query().Get(stateKey), w.Result().Cookies()); w.Code != http.StatusFound {
+		t.Fatalf("want: %v, got: %v", http.StatusFound, w.Code)
+	}
+	if got, want := challengeS256(verifier), u.Query().Get(codeChallengeKey); got != want {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+func TestUpstreamAccessDenied(t *testing.T) {
+	h, done := newTestHandler(t)
+	defer done()
+
+	state, cookies := startFlow(t, h, "google")
+	r := httptest.NewRequest("GET", "https://example.com/exch?error=access_denied&state="+url.QueryEscape(state), nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	h.TokenHandler(w, r)
+	v := redirected(t, w)
+	if got := v.Get(errorKey); got != "access_denied" {
+		t.Errorf("want: access_denied, got: %v", got)
+	}
+	if got := v.Get(stateKey); got != "google" {
+		t.Errorf("want: google, got: %v", got)
+	}
+	if got := v.Get(codeKey); got != "" {
+		t.Errorf("want no code, got: %v", got)
+	}
+}
+
+func TestClientPKCE(t *testing.T) {
+	h, done := newTestHandler(t)
+	defer done()
+
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	for _, tt := range []struct {
+		verifier string
+		want     int
+	}{
+		{"", http.StatusBadRequest},
+		{"wrong", http.StatusBadRequest},
+		{verifier, http.StatusOK},
+	} {
+		w := authorize(h, url.Values{
+			codeChallengeKey:       {challengeS256(verifier)},
+			codeChallengeMethodKey: {PKCEMethodS256},
+		})
+		u, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cb := finishFlow(h, "1", u.Query().Get(stateKey), w.Result().Cookies())
+		w = post(h.GrantHandler, url.Values{
+			grantTypeKey:    {authorizationCodeGrant},
+			codeKey:         {redirected(t, cb).Get(codeKey)},
+			redirectURIKey:  {googleRedirect},
+			codeVerifierKey: {tt.verifier},
+		}, googleSecret)
+		if w.Code != tt.want {
+			t.Errorf("%q: want: %v, got: %v %s", tt.verifier, tt.want, w.Code, w.Body)
+		}
+	}
+}
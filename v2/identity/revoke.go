@@ -0,0 +1,121 @@
+package identity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/damondouglas/go.actions/v2"
+)
+
+// RevokePath is the path to the token revocation endpoint.
+const RevokePath = "revoke"
+
+// ErrNotLinked is returned by Unlink when ctx carries no linked account.
+var ErrNotLinked = errors.New("identity: no linked account")
+
+// RevocationHandler revokes an access or refresh token, as in RFC 7009. Callers
+// authenticate as the client the token was issued to. Unknown tokens are not an error.
+func (h *Handler) RevocationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	client, oerr := h.authenticateClient(r)
+	if oerr != nil {
+		writeError(w, oerr)
+		return
+	}
+	token := r.PostFormValue(tokenKey)
+	if token == "" {
+		writeError(w, errInvalidRequest("missing token"))
+		return
+	}
+	t, err := h.config.Tokens.Access(r.Context(), token)
+	if errors.Is(err, ErrTokenNotFound) {
+		t, err = h.config.Tokens.Refresh(r.Context(), token)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrTokenNotFound):
+		w.WriteHeader(http.StatusOK)
+		return
+	default:
+		writeError(w, errServerError(r.Context(), err))
+		return
+	}
+	if t.ClientID != client.ID {
+		writeError(w, errInvalidGrant("token was issued to another client"))
+		return
+	}
+	if err := h.Revoke(r.Context(), token); err != nil {
+		writeError(w, errServerError(r.Context(), err))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Unlink revokes every token of the account linked to ctx, as when the user asks to be
+// forgotten, and returns enc with resetUserStorage set. It returns ErrNotLinked if
+// ResolveAccount found no linked account.
+func (h *Handler) Unlink(ctx context.Context, enc v2.Encoder) (v2.Encoder, error) {
+	t, ok := TokenFromContext(ctx)
+	if !ok {
+		return nil, ErrNotLinked
+	}
+	tokens, err := h.config.Tokens.User(ctx, t.Subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		token := t.RefreshToken
+		if token == "" {
+			token = t.AccessToken
+		}
+		if err := h.Revoke(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+	return ResetUserStorage(enc), nil
+}
+
+// ResetUserStorage returns enc with the google.Response's resetUserStorage set,
+// clearing the user's storage when Google receives it.
+func ResetUserStorage(enc v2.Encoder) v2.Encoder {
+	return &resetEncoder{enc}
+}
+
+type resetEncoder struct {
+	enc v2.Encoder
+}
+
+// Encode sets payload.google.resetUserStorage on the JSON enc encodes, keeping any
+// fields the response types do not model.
+func (e *resetEncoder) Encode(w io.Writer) error {
+	var buf bytes.Buffer
+	if err := e.enc.Encode(&buf); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(&buf)
+	dec.UseNumber()
+	resp := map[string]interface{}{}
+	if err := dec.Decode(&resp); err != nil {
+		return err
+	}
+	payload := object(resp, "payload")
+	object(payload, "google")["resetUserStorage"] = true
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// object returns m[key] as an object, creating it if missing.
+func object(m map[string]interface{}, key string) map[string]interface{} {
+	o, ok := m[key].(map[string]interface{})
+	if !ok {
+		o = map[string]interface{}{}
+		m[key] = o
+	}
+	return o
+}
@@ -0,0 +1,114 @@
+package identity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+)
+
+func TestRevocationHandler(t *testing.T) {
+	h, done := newTestHandler(t)
+	defer done()
+
+	tok := &tokenResponse{}
+	decode(t, exchange(h, linkedCode(t, h), googleSecret), tok)
+
+	if w := post(h.RevocationHandler, url.Values{tokenKey: {tok.AccessToken}}, "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong secret: want: %v, got: %v", http.StatusUnauthorized, w.Code)
+	}
+	for _, token := range []string{tok.RefreshToken, "unknown"} {
+		if w := post(h.RevocationHandler, url.Values{tokenKey: {token}}, googleSecret); w.Code != http.StatusOK {
+			t.Errorf("%s: want: %v, got: %v %s", token, http.StatusOK, w.Code, w.Body)
+		}
+	}
+	if _, err := h.Introspect(context.Background(), tok.AccessToken); err != ErrTokenNotFound {
+		t.Errorf("want: %v, got: %v", ErrTokenNotFound, err)
+	}
+	w := post(h.GrantHandler, url.Values{
+		grantTypeKey:    {refreshTokenGrant},
+		refreshTokenKey: {tok.RefreshToken},
+	}, googleSecret)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("revoked refresh: want: %v, got: %v", http.StatusBadRequest, w.Code)
+	}
+}
+
+// wrappingStore wraps its store's errors, as a store backed by a database might.
+type wrappingStore struct {
+	TokenStore
+}
+
+func (s wrappingStore) Access(ctx context.Context, token string) (*Token, error) {
+	t, err := s.TokenStore.Access(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("access: %w", err)
+	}
+	return t, nil
+}
+
+func (s wrappingStore) Refresh(ctx context.Context, token string) (*Token, error) {
+	t, err := s.TokenStore.Refresh(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("refresh: %w", err)
+	}
+	return t, nil
+}
+
+func TestRevocationHandlerWrappedErrors(t *testing.T) {
+	cfg := testConfig("")
+	cfg.Tokens = wrappingStore{NewMemoryTokenStore()}
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := post(h.RevocationHandler, url.Values{tokenKey: {"unknown"}}, googleSecret); w.Code != http.StatusOK {
+		t.Errorf("want: %v, got: %v %s", http.StatusOK, w.Code, w.Body)
+	}
+}
+
+func TestUnlink(t *testing.T) {
+	h, done := newTestHandler(t)
+	defer done()
+
+	ctx := context.Background()
+	if _, err := h.Unlink(ctx, &v2.Simple{Say: "bye"}); err != ErrNotLinked {
+		t.Errorf("want: %v, got: %v", ErrNotLinked, err)
+	}
+
+	tok := &tokenResponse{}
+	decode(t, exchange(h, linkedCode(t, h), googleSecret), tok)
+	linked, err := h.Introspect(ctx, tok.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := h.Unlink(NewTokenContext(ctx, linked), &v2.Simple{Say: "bye"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Introspect(ctx, tok.AccessToken); err != ErrTokenNotFound {
+		t.Errorf("want: %v, got: %v", ErrTokenNotFound, err)
+	}
+
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var resp *dialogflow.Response
+	if err := json.Unmarshal(buf.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Payload.Google.ResetUserStorage {
+		t.Errorf("want resetUserStorage, got: %s", buf.Bytes())
+	}
+	if got := resp.Payload.Google.RichResponse.Items[0].SimpleResponse.TextToSpeech; got != "bye" {
+		t.Errorf("want: bye, got: %v", got)
+	}
+}
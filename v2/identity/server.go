@@ -0,0 +1,378 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/damondouglas/go.actions/v2/logger"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// TokenPath is the path to the token endpoint.
+	TokenPath = "token"
+
+	// IntrospectPath is the path to the token introspection endpoint.
+	IntrospectPath = "introspect"
+
+	// DefaultCodeTTL is how long an authorization code may be exchanged.
+	DefaultCodeTTL = 10 * time.Minute
+
+	// DefaultAccessTokenTTL is how long an access token is valid.
+	DefaultAccessTokenTTL = time.Hour
+
+	grantTypeKey        = "grant_type"
+	clientIDKey         = "client_id"
+	clientSecretKey     = "client_secret"
+	refreshTokenKey     = "refresh_token"
+	responseTypeKey     = "response_type"
+	scopeKey            = "scope"
+	tokenKey            = "token"
+	idTokenKey          = "id_token"
+	errorKey            = "error"
+	errorDescriptionKey = "error_description"
+	responseTypeCode    = "code"
+
+	authorizationCodeGrant = "authorization_code"
+	refreshTokenGrant      = "refresh_token"
+
+	tokenSize = 32
+)
+
+// ErrNoSubject is returned when the upstream token does not identify a user.
+var ErrNoSubject = errors.New("identity: upstream token has no subject")
+
+// Client is an OAuth client allowed to link accounts, such as Google.
+type Client struct {
+	ID           string
+	Secret       string
+	RedirectURIs []string
+}
+
+// SubjectFunc identifies the user an upstream token was issued to.
+type SubjectFunc func(ctx context.Context, tok *oauth2.Token) (string, error)
+
+// IDTokenSubject returns the sub claim of the upstream token's OpenID Connect ID token.
+// The token is trusted because it was received directly from the provider's token endpoint.
+func IDTokenSubject(ctx context.Context, tok *oauth2.Token) (string, error) {
+	raw, _ := tok.Extra(idTokenKey).(string)
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return "", ErrNoSubject
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return "", err
+	}
+	if claims.Subject == "" {
+		return "", ErrNoSubject
+	}
+	return claims.Subject, nil
+}
+
+// oauthError is an OAuth 2.0 error response.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	status      int
+}
+
+func (e *oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func errInvalidRequest(description string) *oauthError {
+	return &oauthError{"invalid_request", description, http.StatusBadRequest}
+}
+
+func errInvalidClient() *oauthError {
+	return &oauthError{"invalid_client", "client authentication failed", http.StatusUnauthorized}
+}
+
+func errInvalidGrant(description string) *oauthError {
+	return &oauthError{"invalid_grant", description, http.StatusBadRequest}
+}
+
+// errServerError logs err to the context's logger and returns a server_error that does
+// not disclose it.
+func errServerError(ctx context.Context, err error) *oauthError {
+	logger.FromContext(ctx).Errorf("identity: %v", err)
+	return &oauthError{"server_error", "internal error", http.StatusInternalServerError}
+}
+
+// upstreamError maps the upstream provider's authorization error to ours. Only the
+// user's refusal is passed on; anything else is our server's problem.
+func upstreamError(code, description string) *oauthError {
+	if code == "access_denied" {
+		return &oauthError{Code: code, Description: description}
+	}
+	return &oauthError{Code: "server_error", Description: "upstream: " + code}
+}
+
+func errUnsupportedGrantType(grantType string) *oauthError {
+	return &oauthError{"unsupported_grant_type", grantType, http.StatusBadRequest}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, e *oauthError) {
+	if e.status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="identity"`)
+	}
+	writeJSON(w, e.status, e)
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// authenticateClient returns the client, authenticated by HTTP basic auth or form parameters.
+func (h *Handler) authenticateClient(r *http.Request) (*Client, *oauthError) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostFormValue(clientIDKey), r.PostFormValue(clientSecretKey)
+	}
+	c := h.client(id)
+	if c == nil || subtle.ConstantTimeCompare([]byte(secret), []byte(c.Secret)) != 1 {
+		return nil, errInvalidClient()
+	}
+	return c, nil
+}
+
+func (h *Handler) client(id string) *Client {
+	for _, c := range h.config.Clients {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// issueCode issues an authorization code for subject to the flow's client.
+func (h *Handler) issueCode(ctx context.Context, subject string, upstream *oauth2.Token, flow *Flow) (*Code, error) {
+	code, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	c := &Code{
+		Code:        code,
+		Subject:     subject,
+		ClientID:    flow.ClientID,
+		RedirectURI: flow.RedirectURI,
+		Scopes:      strings.Fields(flow.Scope),
+		Expiry:      time.Now().Add(h.config.CodeTTL),
+		Upstream:    upstream,
+
+		CodeChallenge:       flow.CodeChallenge,
+		CodeChallengeMethod: flow.CodeChallengeMethod,
+	}
+	if err := h.config.Codes.Save(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// issueToken issues an access token, reusing refresh if not nil.
+func (h *Handler) issueToken(ctx context.Context, subject, clientID string, scopes []string, upstream *oauth2.Token, refresh *Token) (*Token, error) {
+	access, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	t := &Token{
+		AccessToken: access,
+		Subject:     subject,
+		ClientID:    clientID,
+		Scopes:      scopes,
+		Expiry:      now.Add(h.config.AccessTokenTTL),
+		Upstream:    upstream,
+	}
+	if refresh != nil {
+		t.RefreshToken = refresh.RefreshToken
+		t.RefreshExpiry = refresh.RefreshExpiry
+	} else {
+		if t.RefreshToken, err = newToken(); err != nil {
+			return nil, err
+		}
+		if h.config.RefreshTokenTTL > 0 {
+			t.RefreshExpiry = now.Add(h.config.RefreshTokenTTL)
+		}
+	}
+	if err := h.config.Tokens.Save(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// GrantHandler is the token endpoint, exchanging authorization codes, refresh tokens and
+// Google ID token assertions for access tokens.
+func (h *Handler) GrantHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	client, oerr := h.authenticateClient(r)
+	if oerr != nil {
+		writeError(w, oerr)
+		return
+	}
+
+	var tok *Token
+	switch grantType := r.PostFormValue(grantTypeKey); grantType {
+	case jwtBearerGrant:
+		h.assertionGrant(w, r, client)
+		return
+	case authorizationCodeGrant:
+		tok, oerr = h.exchangeCode(r, client)
+	case refreshTokenGrant:
+		tok, oerr = h.refresh(r, client)
+	default:
+		oerr = errUnsupportedGrantType(grantType)
+	}
+	if oerr != nil {
+		writeError(w, oerr)
+		return
+	}
+
+	resp := &tokenResponse{
+		AccessToken: tok.AccessToken,
+		TokenType:   bearerKey,
+		ExpiresIn:   int64(time.Until(tok.Expiry) / time.Second),
+		Scope:       strings.Join(tok.Scopes, " "),
+	}
+	if r.PostFormValue(grantTypeKey) == authorizationCodeGrant {
+		resp.RefreshToken = tok.RefreshToken
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) exchangeCode(r *http.Request, client *Client) (*Token, *oauthError) {
+	code := r.PostFormValue(codeKey)
+	if code == "" {
+		return nil, errInvalidRequest("missing code")
+	}
+	c, err := h.config.Codes.Take(r.Context(), code)
+	if err != nil || time.Now().After(c.Expiry) {
+		return nil, errInvalidGrant("invalid or expired code")
+	}
+	if c.ClientID != client.ID {
+		return nil, errInvalidGrant("code was issued to another client")
+	}
+	if c.RedirectURI != "" && r.PostFormValue(redirectURIKey) != c.RedirectURI {
+		return nil, errInvalidGrant("redirect_uri does not match")
+	}
+	if !verifyPKCE(c.CodeChallenge, c.CodeChallengeMethod, r.PostFormValue(codeVerifierKey)) {
+		return nil, errInvalidGrant("code_verifier does not match")
+	}
+	tok, err := h.issueToken(r.Context(), c.Subject, c.ClientID, c.Scopes, c.Upstream, nil)
+	if err != nil {
+		return nil, errServerError(r.Context(), err)
+	}
+	return tok, nil
+}
+
+func (h *Handler) refresh(r *http.Request, client *Client) (*Token, *oauthError) {
+	refresh := r.PostFormValue(refreshTokenKey)
+	if refresh == "" {
+		return nil, errInvalidRequest("missing refresh_token")
+	}
+	t, err := h.config.Tokens.Refresh(r.Context(), refresh)
+	if err != nil || !t.Refreshable(time.Now()) {
+		return nil, errInvalidGrant("invalid or expired refresh_token")
+	}
+	if t.ClientID != client.ID {
+		return nil, errInvalidGrant("refresh_token was issued to another client")
+	}
+	tok, err := h.issueToken(r.Context(), t.Subject, t.ClientID, t.Scopes, t.Upstream, t)
+	if err != nil {
+		return nil, errServerError(r.Context(), err)
+	}
+	return tok, nil
+}
+
+type introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// IntrospectHandler reports whether a token is active, as in RFC 7662. Callers
+// authenticate as a registered client.
+func (h *Handler) IntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if _, oerr := h.authenticateClient(r); oerr != nil {
+		writeError(w, oerr)
+		return
+	}
+	token := r.PostFormValue(tokenKey)
+	if token == "" {
+		writeError(w, errInvalidRequest("missing token"))
+		return
+	}
+	resp := &introspection{}
+	if t, err := h.Introspect(r.Context(), token); err == nil {
+		resp = &introspection{
+			Active:    true,
+			Scope:     strings.Join(t.Scopes, " "),
+			ClientID:  t.ClientID,
+			Subject:   t.Subject,
+			TokenType: bearerKey,
+			ExpiresAt: t.Expiry.Unix(),
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Introspect returns the active token issued for access token.
+func (h *Handler) Introspect(ctx context.Context, token string) (*Token, error) {
+	t, err := h.config.Tokens.Access(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !t.Active(time.Now()) {
+		return nil, ErrExpiredToken
+	}
+	return t, nil
+}
+
+// Revoke revokes an access or refresh token, as when a user unlinks their account.
+func (h *Handler) Revoke(ctx context.Context, token string) error {
+	return h.config.Tokens.Revoke(ctx, token)
+}
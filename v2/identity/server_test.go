@@ -0,0 +1,187 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// linkedCode runs an account linking flow and returns the code issued to Google.
+func linkedCode(t *testing.T, h *Handler) string {
+	state, cookies := startFlow(t, h, "google")
+	w := finishFlow(h, "1", state, cookies)
+	if w.Code != http.StatusFound {
+		t.Fatalf("want: %v, got: %v %s", http.StatusFound, w.Code, w.Body)
+	}
+	return redirected(t, w).Get("code")
+}
+
+// post calls handler with form, authenticated as Google.
+func post(h http.HandlerFunc, form url.Values, secret string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "https://example.com/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth(googleID, secret)
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestHandler(t *testing.T) (*Handler, func()) {
+	provider := newProvider()
+	h, err := New(testConfig(provider.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, provider.Close
+}
+
+func exchange(h *Handler, code, secret string) *httptest.ResponseRecorder {
+	return post(h.GrantHandler, url.Values{
+		grantTypeKey:   {authorizationCodeGrant},
+		codeKey:        {code},
+		redirectURIKey: {googleRedirect},
+	}, secret)
+}
+
+func TestAuthorizationCodeGrant(t *testing.T) {
+	h, done := newTestHandler(t)
+	defer done()
+
+	code := linkedCode(t, h)
+	w := exchange(h, code, googleSecret)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: %v, got: %v %s", http.StatusOK, w.Code, w.Body)
+	}
+	resp := &tokenResponse{}
+	decode(t, w, resp)
+	if resp.AccessToken == "" || resp.RefreshToken == "" {
+		t.Fatalf("want access and refresh tokens, got: %+v", resp)
+	}
+	tok, err := h.Introspect(context.Background(), resp.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "user-token-1"; tok.Subject != want {
+		t.Errorf("want: %v, got: %v", want, tok.Subject)
+	}
+
+	if w := exchange(h, code, googleSecret); w.Code != http.StatusBadRequest {
+		t.Errorf("reused code: want: %v, got: %v", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestInvalidClient(t *testing.T) {
+	h, done := newTestHandler(t)
+	defer done()
+
+	w := exchange(h, linkedCode(t, h), "wrong")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("want: %v, got: %v", http.StatusUnauthorized, w.Code)
+	}
+	e := &oauthError{}
+	decode(t, w, e)
+	if want := "invalid_client"; e.Code != want {
+		t.Errorf("want: %v, got: %v", want, e.Code)
+	}
+}
+
+func TestRefreshTokenGrant(t *testing.T) {
+	h, done := newTestHandler(t)
+	defer done()
+
+	first := &tokenResponse{}
+	decode(t, exchange(h, linkedCode(t, h), googleSecret), first)
+
+	w := post(h.GrantHandler, url.Values{
+		grantTypeKey:    {refreshTokenGrant},
+		refreshTokenKey: {first.RefreshToken},
+	}, googleSecret)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: %v, got: %v %s", http.StatusOK, w.Code, w.Body)
+	}
+	second := &tokenResponse{}
+	decode(t, w, second)
+	if second.AccessToken == "" || second.AccessToken == first.AccessToken {
+		t.Errorf("want new access token, got: %v", second.AccessToken)
+	}
+	if _, err := h.Introspect(context.Background(), second.AccessToken); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntrospectHandler(t *testing.T) {
+	h, done := newTestHandler(t)
+	defer done()
+
+	tok := &tokenResponse{}
+	decode(t, exchange(h, linkedCode(t, h), googleSecret), tok)
+
+	for _, tt := range []struct {
+		token string
+		want  bool
+	}{
+		{tok.AccessToken, true},
+		{"unknown", false},
+	} {
+		w := post(h.IntrospectHandler, url.Values{tokenKey: {tt.token}}, googleSecret)
+		if w.Code != http.StatusOK {
+			t.Fatalf("want: %v, got: %v", http.StatusOK, w.Code)
+		}
+		got := &introspection{}
+		decode(t, w, got)
+		if got.Active != tt.want {
+			t.Errorf("%s: want: %v, got: %v", tt.token, tt.want, got.Active)
+		}
+	}
+}
+
+func TestSharedStores(t *testing.T) {
+	provider := newProvider()
+	defer provider.Close()
+	shared := testConfig(provider.URL)
+	shared.Codes = NewMemoryCodeStore()
+	shared.Tokens = NewMemoryTokenStore()
+	a, err := New(shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := exchange(b, linkedCode(t, a), googleSecret)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: %v, got: %v %s", http.StatusOK, w.Code, w.Body)
+	}
+}
+
+func TestServerErrorHidden(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database password is hunter2", http.StatusInternalServerError)
+	}))
+	defer provider.Close()
+	h, err := New(testConfig(provider.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, cookies := startFlow(t, h, "google")
+	v := redirected(t, finishFlow(h, "1", state, cookies))
+	if got := v.Get(errorKey); got != "server_error" {
+		t.Errorf("want: server_error, got: %v", got)
+	}
+	if got := v.Get(errorDescriptionKey); strings.Contains(got, "hunter2") {
+		t.Errorf("want error hidden, got: %v", got)
+	}
+}
@@ -0,0 +1,127 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/auth"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/fulfillment"
+	"github.com/damondouglas/go.actions/v2/logger"
+)
+
+const (
+	jwtBearerGrant = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	assertionKey   = "assertion"
+	intentKey      = "intent"
+
+	intentCheck  = "check"
+	intentGet    = "get"
+	intentCreate = "create"
+)
+
+// ErrAccountNotFound is returned by an AccountFunc asked to find an account that does not exist.
+var ErrAccountNotFound = errors.New("identity: account not found")
+
+// AccountFunc finds the account of the user signed in to Google as id, creating it if
+// create is set, and returns its subject. When create is not set and there is no
+// account, it returns ErrAccountNotFound, which may be wrapped.
+type AccountFunc func(ctx context.Context, id *auth.Identity, create bool) (subject string, err error)
+
+// SignIn links accounts by Google Sign-In: it verifies the request's google.User.IDToken,
+// when present, finds or creates the account with account, and puts the verified
+// identity and the account in the handler's context for RequireAccount and
+// TokenFromContext. Like ResolveAccount with an expired access token, a token that is
+// expired or otherwise fails verification leaves the request unlinked, so RequireAccount
+// asks the user to sign in again.
+func SignIn(v *auth.IDTokenVerifier, account AccountFunc) fulfillment.Middleware {
+	return func(next fulfillment.Handler) fulfillment.Handler {
+		return fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+			payload := req.OriginalDetectIntentRequest.Payload
+			if payload == nil || payload.User == nil || payload.User.IDToken == "" {
+				return next.Fulfill(ctx, req)
+			}
+			id, err := v.Verify(ctx, payload.User.IDToken)
+			if err != nil {
+				logger.FromContext(ctx).Infof("identity: ignoring ID token: %v", err)
+				return next.Fulfill(ctx, req)
+			}
+			subject, err := account(ctx, id, true)
+			if err != nil {
+				return nil, err
+			}
+			ctx = auth.NewIdentityContext(ctx, id)
+			ctx = NewTokenContext(ctx, &Token{Subject: subject})
+			return next.Fulfill(ctx, req)
+		})
+	}
+}
+
+type accountFound struct {
+	AccountFound string `json:"account_found"`
+}
+
+// assertionGrant handles Google's jwt-bearer grant for OAuth and Google Sign-In linking.
+// The assertion is a Google ID token; intent check reports whether its account exists,
+// answering account_found "true", or "false" with 404, get issues tokens for an
+// existing account, and create creates the account first.
+func (h *Handler) assertionGrant(w http.ResponseWriter, r *http.Request, client *Client) {
+	if h.config.IDTokens == nil || h.config.Account == nil {
+		writeError(w, errUnsupportedGrantType(jwtBearerGrant))
+		return
+	}
+	assertion := r.PostFormValue(assertionKey)
+	if assertion == "" {
+		writeError(w, errInvalidRequest("missing assertion"))
+		return
+	}
+	id, err := h.config.IDTokens.Verify(r.Context(), assertion)
+	if err != nil {
+		writeError(w, errInvalidGrant(err.Error()))
+		return
+	}
+
+	intent := r.PostFormValue(intentKey)
+	var create bool
+	switch intent {
+	case intentCheck, intentGet:
+	case intentCreate:
+		create = true
+	default:
+		writeError(w, errInvalidRequest("unsupported intent"))
+		return
+	}
+	subject, err := h.config.Account(r.Context(), id, create)
+	switch {
+	case errors.Is(err, ErrAccountNotFound) && intent == intentCheck:
+		writeJSON(w, http.StatusNotFound, &accountFound{"false"})
+		return
+	case errors.Is(err, ErrAccountNotFound):
+		writeError(w, &oauthError{"user_not_found", "", http.StatusUnauthorized})
+		return
+	case err != nil:
+		writeError(w, errServerError(r.Context(), err))
+		return
+	}
+	if intent == intentCheck {
+		writeJSON(w, http.StatusOK, &accountFound{"true"})
+		return
+	}
+
+	tok, err := h.issueToken(r.Context(), subject, client.ID, strings.Fields(r.PostFormValue(scopeKey)), nil, nil)
+	if err != nil {
+		writeError(w, errServerError(r.Context(), err))
+		return
+	}
+	writeJSON(w, http.StatusOK, &tokenResponse{
+		AccessToken:  tok.AccessToken,
+		TokenType:    bearerKey,
+		ExpiresIn:    int64(time.Until(tok.Expiry) / time.Second),
+		RefreshToken: tok.RefreshToken,
+		Scope:        strings.Join(tok.Scopes, " "),
+	})
+}
@@ -0,0 +1,195 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/auth"
+	"github.com/damondouglas/go.actions/v2/auth/authtest"
+	"github.com/damondouglas/go.actions/v2/fulfillment"
+	"github.com/damondouglas/go.actions/v2/google"
+)
+
+const signInClientID = "123.apps.googleusercontent.com"
+
+// accounts creates accounts named after the Google subject.
+type accounts struct {
+	mu       sync.Mutex
+	subjects map[string]string
+}
+
+func (a *accounts) account(ctx context.Context, id *auth.Identity, create bool) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if s, ok := a.subjects[id.Subject]; ok {
+		return s, nil
+	}
+	if !create {
+		return "", fmt.Errorf("account %s: %w", id.Subject, ErrAccountNotFound)
+	}
+	a.subjects[id.Subject] = "account-" + id.Subject
+	return a.subjects[id.Subject], nil
+}
+
+// newSignIn returns a verifier of ID tokens signed by the returned signer.
+func newSignIn(t *testing.T) (*auth.IDTokenVerifier, *authtest.Signer, func()) {
+	signer, err := authtest.NewSigner("id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := signer.Server()
+	v, err := auth.NewIDTokenVerifier(&auth.IDTokenConfig{
+		ClientID: signInClientID,
+		JWKSURL:  srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v, signer, srv.Close
+}
+
+func idToken(t *testing.T, signer *authtest.Signer, subject string) string {
+	token, err := signer.Sign(&auth.Identity{
+		Claims: auth.Claims{
+			Issuer:    "https://accounts.google.com",
+			Subject:   subject,
+			Audience:  auth.Audience{signInClientID},
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+		Email: "user@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestSignIn(t *testing.T) {
+	v, signer, done := newSignIn(t)
+	defer done()
+	a := &accounts{subjects: map[string]string{}}
+	h := fulfillment.Chain(whoami, SignIn(v, a.account), RequireAccount(""))
+
+	req := linkedRequest("")
+	req.OriginalDetectIntentRequest.Payload.User = &google.User{IDToken: idToken(t, signer, "42")}
+	enc, err := h.Fulfill(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := enc.(*v2.Simple).Say; got != "account-42" {
+		t.Errorf("want: account-42, got: %v", got)
+	}
+
+	req.OriginalDetectIntentRequest.Payload.User.IDToken = "forged"
+	enc, err = h.Fulfill(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := systemIntent(t, enc), "actions.intent.SIGN_IN"; got != want {
+		t.Errorf("invalid ID token: want: %v, got: %v", want, got)
+	}
+}
+
+func TestSignInExpired(t *testing.T) {
+	v, signer, done := newSignIn(t)
+	defer done()
+	a := &accounts{subjects: map[string]string{}}
+	h := fulfillment.Chain(whoami, SignIn(v, a.account), RequireAccount(""))
+
+	token, err := signer.Sign(&auth.Identity{
+		Claims: auth.Claims{
+			Issuer:    "https://accounts.google.com",
+			Subject:   "42",
+			Audience:  auth.Audience{signInClientID},
+			ExpiresAt: time.Now().Add(-time.Hour).Unix(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := linkedRequest("")
+	req.OriginalDetectIntentRequest.Payload.User = &google.User{IDToken: token}
+	enc, err := h.Fulfill(context.Background(), req)
+	if err != nil {
+		t.Fatalf("want expired ID token treated as signed out, got: %v", err)
+	}
+	if got, want := systemIntent(t, enc), "actions.intent.SIGN_IN"; got != want {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+	if len(a.subjects) != 0 {
+		t.Errorf("want no account created, got: %v", a.subjects)
+	}
+}
+
+func TestAssertionGrant(t *testing.T) {
+	v, signer, done := newSignIn(t)
+	defer done()
+	provider := newProvider()
+	defer provider.Close()
+	cfg := testConfig(provider.URL)
+	cfg.IDTokens = v
+	cfg.Account = (&accounts{subjects: map[string]string{}}).account
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertion := idToken(t, signer, "42")
+	grant := func(intent, assertion string) (int, map[string]interface{}) {
+		w := post(h.GrantHandler, url.Values{
+			grantTypeKey: {jwtBearerGrant},
+			intentKey:    {intent},
+			assertionKey: {assertion},
+		}, googleSecret)
+		resp := map[string]interface{}{}
+		decode(t, w, &resp)
+		return w.Code, resp
+	}
+
+	for _, tt := range []struct {
+		intent    string
+		assertion string
+		code      int
+		key       string
+		want      interface{}
+	}{
+		{intentCheck, assertion, http.StatusNotFound, "account_found", "false"},
+		{intentGet, assertion, http.StatusUnauthorized, "error", "user_not_found"},
+		{intentCreate, "forged", http.StatusBadRequest, "error", "invalid_grant"},
+		{intentCreate, assertion, http.StatusOK, "token_type", bearerKey},
+		{intentCheck, assertion, http.StatusOK, "account_found", "true"},
+		{intentGet, assertion, http.StatusOK, "token_type", bearerKey},
+	} {
+		code, resp := grant(tt.intent, tt.assertion)
+		if code != tt.code || resp[tt.key] != tt.want {
+			t.Errorf("%s: want: %v %v, got: %v %v", tt.intent, tt.code, tt.want, code, resp)
+		}
+	}
+
+	_, resp := grant(intentGet, assertion)
+	tok, err := h.Introspect(context.Background(), resp["access_token"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Subject != "account-42" {
+		t.Errorf("want: account-42, got: %v", tok.Subject)
+	}
+}
+
+func TestAssertionGrantUnsupported(t *testing.T) {
+	h, done := newTestHandler(t)
+	defer done()
+
+	w := post(h.GrantHandler, url.Values{grantTypeKey: {jwtBearerGrant}}, googleSecret)
+	e := &oauthError{}
+	decode(t, w, e)
+	if e.Code != "unsupported_grant_type" {
+		t.Errorf("want: unsupported_grant_type, got: %v", e.Code)
+	}
+}
@@ -0,0 +1,261 @@
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	stateCookiePrefix = "identity_state_"
+	nonceSize         = 16
+
+	// MinStateKeySize is the shortest CookieStateStore.Key accepted.
+	MinStateKeySize = 32
+
+	// DefaultStateTTL is how long an account linking flow may take.
+	DefaultStateTTL = 10 * time.Minute
+)
+
+var (
+	// ErrInvalidState is returned when a callback's state does not match a pending flow.
+	ErrInvalidState = errors.New("identity: invalid state")
+
+	// ErrExpiredState is returned when a callback arrives after its flow expired.
+	ErrExpiredState = errors.New("identity: expired state")
+
+	// ErrStateKeyTooShort is returned by a CookieStateStore whose Key is shorter than
+	// MinStateKeySize, since anyone could forge cookies signed with it.
+	ErrStateKeyTooShort = errors.New("identity: state key is shorter than 32 bytes")
+)
+
+// Flow is the state of one account linking flow, kept while the user is at the upstream provider.
+type Flow struct {
+	// State is the state Google sent to AuthPath, returned to Google when linking completes.
+	State string `json:"state"`
+
+	// RedirectURI is where Google asked to be redirected.
+	RedirectURI string `json:"redirectUri,omitempty"`
+
+	// ClientID is the client linking the account.
+	ClientID string `json:"clientId,omitempty"`
+
+	// Scope is the space separated scopes the client requested.
+	Scope string `json:"scope,omitempty"`
+
+	// CodeChallenge and CodeChallengeMethod are the client's PKCE challenge, if any.
+	CodeChallenge       string `json:"codeChallenge,omitempty"`
+	CodeChallengeMethod string `json:"codeChallengeMethod,omitempty"`
+
+	// Verifier is the PKCE code verifier for the upstream exchange.
+	Verifier string `json:"verifier,omitempty"`
+
+	// Expiry is when the flow expires.
+	Expiry time.Time `json:"expiry"`
+}
+
+// StateStore keeps flows between AuthPath and the upstream provider's callback.
+type StateStore interface {
+	// Save stores f and returns the opaque state to send to the upstream provider.
+	Save(w http.ResponseWriter, r *http.Request, f *Flow) (state string, err error)
+
+	// Load returns and forgets the flow for the state the upstream provider returned.
+	Load(w http.ResponseWriter, r *http.Request, state string) (*Flow, error)
+}
+
+// stateCookie returns the cookie that carries the state of the flow nonce to the
+// upstream provider's callback. Browsers send a SameSite=Lax cookie with a redirect
+// back, a top-level GET, but not with a cross-site form post. For that the cookie must
+// be SameSite=None, which browsers only accept on Secure cookies.
+func stateCookie(nonce, value string, expiry time.Time, secure, formPost bool) *http.Cookie {
+	c := &http.Cookie{
+		Name:     stateCookiePrefix + nonce,
+		Value:    value,
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if formPost {
+		c.Secure = true
+		c.SameSite = http.SameSiteNoneMode
+	}
+	return c
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, nonceSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CookieStateStore keeps flows in HMAC-signed cookies, one per flow, so it needs no
+// server-side storage and works across instances sharing Key. Key must be at least
+// MinStateKeySize random bytes.
+type CookieStateStore struct {
+	Key    []byte
+	Secure bool
+
+	// FormPost supports upstream callbacks by form post, as with response_mode=form_post.
+	// See stateCookie.
+	FormPost bool
+}
+
+// Validate returns ErrStateKeyTooShort if Key is too short.
+func (s *CookieStateStore) Validate() error {
+	if len(s.Key) < MinStateKeySize {
+		return ErrStateKeyTooShort
+	}
+	return nil
+}
+
+type cookieFlow struct {
+	Nonce string `json:"nonce"`
+	Flow
+}
+
+// Save implements StateStore.
+func (s *CookieStateStore) Save(w http.ResponseWriter, r *http.Request, f *Flow) (string, error) {
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(&cookieFlow{Nonce: nonce, Flow: *f})
+	if err != nil {
+		return "", err
+	}
+	value := base64.RawURLEncoding.EncodeToString(data)
+	http.SetCookie(w, stateCookie(nonce, value+"."+s.sign(value), f.Expiry, s.Secure, s.FormPost))
+	return nonce, nil
+}
+
+// Load implements StateStore.
+func (s *CookieStateStore) Load(w http.ResponseWriter, r *http.Request, state string) (*Flow, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	c, err := r.Cookie(stateCookiePrefix + state)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:   c.Name,
+		Path:   "/",
+		MaxAge: -1,
+	})
+
+	parts := strings.SplitN(c.Value, ".", 2)
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(s.sign(parts[0]))) {
+		return nil, ErrInvalidState
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	cf := &cookieFlow{}
+	if err := json.Unmarshal(data, cf); err != nil {
+		return nil, ErrInvalidState
+	}
+	if subtle.ConstantTimeCompare([]byte(cf.Nonce), []byte(state)) != 1 {
+		return nil, ErrInvalidState
+	}
+	if time.Now().After(cf.Expiry) {
+		return nil, ErrExpiredState
+	}
+	return &cf.Flow, nil
+}
+
+func (s *CookieStateStore) sign(value string) string {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// MemoryStateStore keeps flows in memory. It suits a single instance. Each flow is bound
+// to the browser that started it by a cookie, so a state cannot be completed from
+// another browser, as in login CSRF.
+type MemoryStateStore struct {
+	// Secure marks the binding cookies Secure.
+	Secure bool
+
+	// FormPost supports upstream callbacks by form post, as with response_mode=form_post.
+	// See stateCookie.
+	FormPost bool
+
+	mu    sync.Mutex
+	flows map[string]*memoryFlow
+}
+
+type memoryFlow struct {
+	flow    *Flow
+	binding string
+}
+
+// NewMemoryStateStore returns an empty MemoryStateStore.
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		flows: map[string]*memoryFlow{},
+	}
+}
+
+// Save implements StateStore.
+func (s *MemoryStateStore) Save(w http.ResponseWriter, r *http.Request, f *Flow) (string, error) {
+	nonce, err := newNonce()
+	if err != nil {
+		return "", err
+	}
+	binding, err := newNonce()
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, stateCookie(nonce, binding, f.Expiry, s.Secure, s.FormPost))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, v := range s.flows {
+		if now.After(v.flow.Expiry) {
+			delete(s.flows, k)
+		}
+	}
+	s.flows[nonce] = &memoryFlow{flow: f, binding: binding}
+	return nonce, nil
+}
+
+// Load implements StateStore.
+func (s *MemoryStateStore) Load(w http.ResponseWriter, r *http.Request, state string) (*Flow, error) {
+	c, err := r.Cookie(stateCookiePrefix + state)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mf, ok := s.flows[state]
+	if !ok || subtle.ConstantTimeCompare([]byte(c.Value), []byte(mf.binding)) != 1 {
+		return nil, ErrInvalidState
+	}
+	delete(s.flows, state)
+	http.SetCookie(w, &http.Cookie{
+		Name:   c.Name,
+		Path:   "/",
+		MaxAge: -1,
+	})
+	if time.Now().After(mf.flow.Expiry) {
+		return nil, ErrExpiredState
+	}
+	return mf.flow, nil
+}
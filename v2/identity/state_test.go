@@ -0,0 +1,235 @@
+package identity
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newProvider serves a token endpoint that issues "token-<code>".
+func newProvider() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "token-" + r.FormValue("code"),
+			"token_type":   "bearer",
+		})
+	}))
+}
+
+// startFlow calls AuthHandler with Google's state and returns the upstream state and cookies.
+func startFlow(t *testing.T, h *Handler, googleState string) (string, []*http.Cookie) {
+	w := httptest.NewRecorder()
+	h.AuthHandler(w, httptest.NewRequest("GET", authURL(googleState), nil))
+	u, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query().Get("state"), w.Result().Cookies()
+}
+
+// finishFlow calls TokenHandler as the upstream provider would and returns the redirect to Google.
+func finishFlow(h *Handler, code, state string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "https://example.com/exch?code="+code+"&state="+url.QueryEscape(state), nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	h.TokenHandler(w, r)
+	return w
+}
+
+// redirected returns the query of the redirect back to Google.
+func redirected(t *testing.T, w *httptest.ResponseRecorder) url.Values {
+	u, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query()
+}
+
+func testStateStores() map[string]StateStore {
+	return map[string]StateStore{
+		"memory": NewMemoryStateStore(),
+		"cookie": &CookieStateStore{Key: []byte("0123456789abcdef0123456789abcdef")},
+	}
+}
+
+func TestConcurrentFlows(t *testing.T) {
+	provider := newProvider()
+	defer provider.Close()
+
+	for name, store := range testStateStores() {
+		cfg := testConfig(provider.URL)
+		cfg.States = store
+		h, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		const flows = 10
+		errs := make(chan error, flows)
+		var wg sync.WaitGroup
+		for i := 0; i < flows; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs <- runFlow(h, i)
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+}
+
+// runFlow links an account with code i, returning errors rather than failing the test,
+// so it can run in its own goroutine.
+func runFlow(h *Handler, i int) error {
+	googleState := fmt.Sprintf("google-%d", i)
+	w := httptest.NewRecorder()
+	h.AuthHandler(w, httptest.NewRequest("GET", authURL(googleState), nil))
+	u, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		return err
+	}
+	cb := finishFlow(h, fmt.Sprint(i), u.Query().Get(stateKey), w.Result().Cookies())
+	if cb.Code != http.StatusFound {
+		return fmt.Errorf("want: %v, got: %v %s", http.StatusFound, cb.Code, cb.Body)
+	}
+	u, err = url.Parse(cb.Header().Get("Location"))
+	if err != nil {
+		return err
+	}
+	v := u.Query()
+	if got := v.Get(stateKey); got != googleState {
+		return fmt.Errorf("want: %v, got: %v", googleState, got)
+	}
+	c, ok := takeCode(h, v.Get(codeKey))
+	if !ok {
+		return fmt.Errorf("code not issued")
+	}
+	if want := fmt.Sprintf("user-token-%d", i); c.Subject != want {
+		return fmt.Errorf("want: %v, got: %v", want, c.Subject)
+	}
+	return nil
+}
+
+func TestForgedState(t *testing.T) {
+	provider := newProvider()
+	defer provider.Close()
+
+	for name, store := range testStateStores() {
+		cfg := testConfig(provider.URL)
+		cfg.States = store
+		h, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		state, cookies := startFlow(t, h, "google")
+		if w := finishFlow(h, "1", "forged", cookies); w.Code != http.StatusForbidden {
+			t.Errorf("%s: forged: want: %v, got: %v", name, http.StatusForbidden, w.Code)
+		}
+		if w := finishFlow(h, "1", state, cookies); w.Code != http.StatusFound {
+			t.Errorf("%s: valid: want: %v, got: %v", name, http.StatusFound, w.Code)
+		}
+		if name == "memory" {
+			if w := finishFlow(h, "1", state, cookies); w.Code != http.StatusForbidden {
+				t.Errorf("%s: replay: want: %v, got: %v", name, http.StatusForbidden, w.Code)
+			}
+		}
+	}
+}
+
+func TestStateBoundToBrowser(t *testing.T) {
+	provider := newProvider()
+	defer provider.Close()
+
+	for name, store := range testStateStores() {
+		cfg := testConfig(provider.URL)
+		cfg.States = store
+		h, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		state, _ := startFlow(t, h, "google")
+		if w := finishFlow(h, "1", state, nil); w.Code != http.StatusForbidden {
+			t.Errorf("%s: other browser: want: %v, got: %v", name, http.StatusForbidden, w.Code)
+		}
+	}
+}
+
+func TestStateKeyTooShort(t *testing.T) {
+	cfg := testConfig("")
+	cfg.States = &CookieStateStore{Key: []byte("short")}
+	if _, err := New(cfg); err != ErrStateKeyTooShort {
+		t.Errorf("want: %v, got: %v", ErrStateKeyTooShort, err)
+	}
+}
+
+// crossSite returns the cookies a browser sends with a cross-site form post: only
+// SameSite=None cookies, which must be Secure.
+func crossSite(cookies []*http.Cookie) []*http.Cookie {
+	var sent []*http.Cookie
+	for _, c := range cookies {
+		if c.SameSite == http.SameSiteNoneMode && c.Secure {
+			sent = append(sent, c)
+		}
+	}
+	return sent
+}
+
+// postFlow calls TokenHandler as an upstream provider using response_mode=form_post would.
+func postFlow(h *Handler, code, state string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	form := url.Values{codeKey: {code}, stateKey: {state}}
+	r := httptest.NewRequest("POST", "https://example.com/exch", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	h.TokenHandler(w, r)
+	return w
+}
+
+func TestFormPostCallback(t *testing.T) {
+	provider := newProvider()
+	defer provider.Close()
+
+	for _, formPost := range []bool{false, true} {
+		memory := NewMemoryStateStore()
+		memory.FormPost = formPost
+		stores := map[string]StateStore{
+			"memory": memory,
+			"cookie": &CookieStateStore{Key: []byte("0123456789abcdef0123456789abcdef"), FormPost: formPost},
+		}
+		for name, store := range stores {
+			cfg := testConfig(provider.URL)
+			cfg.States = store
+			h, err := New(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := http.StatusForbidden
+			if formPost {
+				want = http.StatusFound
+			}
+			state, cookies := startFlow(t, h, "google")
+			if w := postFlow(h, "1", state, crossSite(cookies)); w.Code != want {
+				t.Errorf("%s: FormPost %v: want: %v, got: %v %s", name, formPost, want, w.Code, w.Body)
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTokenNotFound is returned when a token was never issued or has been revoked.
+	ErrTokenNotFound = errors.New("identity: token not found")
+
+	// ErrExpiredToken is returned when an access token has expired.
+	ErrExpiredToken = errors.New("identity: expired token")
+)
+
+// TokenStore keeps the tokens issued to clients.
+type TokenStore interface {
+	// Save stores t, keyed by its access token. It replaces any token with the same
+	// refresh token, which t supersedes.
+	Save(ctx context.Context, t *Token) error
+
+	// Access returns the token with access token, or ErrTokenNotFound.
+	Access(ctx context.Context, accessToken string) (*Token, error)
+
+	// Refresh returns the latest token with refresh token, or ErrTokenNotFound.
+	Refresh(ctx context.Context, refreshToken string) (*Token, error)
+
+	// User returns the tokens issued on behalf of subject.
+	User(ctx context.Context, subject string) ([]*Token, error)
+
+	// Revoke removes the tokens with access or refresh token. Revoking a refresh token
+	// also revokes the access tokens issued with it. Unknown tokens are ignored.
+	Revoke(ctx context.Context, token string) error
+}
+
+// MemoryTokenStore keeps tokens in memory. It suits a single instance.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*Token
+}
+
+// NewMemoryTokenStore returns an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens: map[string]*Token{},
+	}
+}
+
+// Save implements TokenStore.
+func (s *MemoryTokenStore) Save(ctx context.Context, t *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.save(t)
+	return nil
+}
+
+func (s *MemoryTokenStore) save(t *Token) {
+	now := time.Now()
+	for k, v := range s.tokens {
+		superseded := t.RefreshToken != "" && v.RefreshToken == t.RefreshToken
+		if superseded || (!v.Active(now) && !v.Refreshable(now)) {
+			delete(s.tokens, k)
+		}
+	}
+	c := *t
+	s.tokens[t.AccessToken] = &c
+}
+
+// Access implements TokenStore.
+func (s *MemoryTokenStore) Access(ctx context.Context, accessToken string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[accessToken]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	c := *t
+	return &c, nil
+}
+
+// Refresh implements TokenStore.
+func (s *MemoryTokenStore) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var latest *Token
+	for _, t := range s.tokens {
+		if t.RefreshToken == refreshToken && (latest == nil || t.Expiry.After(latest.Expiry)) {
+			latest = t
+		}
+	}
+	if refreshToken == "" || latest == nil {
+		return nil, ErrTokenNotFound
+	}
+	c := *latest
+	return &c, nil
+}
+
+// User implements TokenStore.
+func (s *MemoryTokenStore) User(ctx context.Context, subject string) ([]*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var tokens []*Token
+	for _, t := range s.tokens {
+		if t.Subject == subject {
+			c := *t
+			tokens = append(tokens, &c)
+		}
+	}
+	return tokens, nil
+}
+
+// Revoke implements TokenStore.
+func (s *MemoryTokenStore) Revoke(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoke(token)
+	return nil
+}
+
+func (s *MemoryTokenStore) revoke(token string) {
+	for k, t := range s.tokens {
+		if t.AccessToken == token || (token != "" && t.RefreshToken == token) {
+			delete(s.tokens, k)
+		}
+	}
+}
+
+// FileTokenStore keeps tokens in memory and persists them to a JSON file after every
+// change, so they survive restarts. It suits a single instance.
+type FileTokenStore struct {
+	path string
+	mem  *MemoryTokenStore
+}
+
+// NewFileTokenStore returns a FileTokenStore persisted at path, loading any tokens
+// already saved there.
+func NewFileTokenStore(path string) (*FileTokenStore, error) {
+	s := &FileTokenStore{
+		path: path,
+		mem:  NewMemoryTokenStore(),
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tokens []*Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		s.mem.tokens[t.AccessToken] = t
+	}
+	return s, nil
+}
+
+// Save implements TokenStore.
+func (s *FileTokenStore) Save(ctx context.Context, t *Token) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	s.mem.save(t)
+	return s.persist()
+}
+
+// Access implements TokenStore.
+func (s *FileTokenStore) Access(ctx context.Context, accessToken string) (*Token, error) {
+	return s.mem.Access(ctx, accessToken)
+}
+
+// Refresh implements TokenStore.
+func (s *FileTokenStore) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	return s.mem.Refresh(ctx, refreshToken)
+}
+
+// User implements TokenStore.
+func (s *FileTokenStore) User(ctx context.Context, subject string) ([]*Token, error) {
+	return s.mem.User(ctx, subject)
+}
+
+// Revoke implements TokenStore.
+func (s *FileTokenStore) Revoke(ctx context.Context, token string) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	s.mem.revoke(token)
+	return s.persist()
+}
+
+// persist writes every token to a temporary file and renames it over path, so a
+// crash never leaves a partial file. The caller holds s.mem.mu.
+func (s *FileTokenStore) persist() error {
+	tokens := make([]*Token, 0, len(s.mem.tokens))
+	for _, t := range s.mem.tokens {
+		tokens = append(tokens, t)
+	}
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
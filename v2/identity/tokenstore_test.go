@@ -0,0 +1,145 @@
+package identity
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testToken(access, refresh, subject string) *Token {
+	return &Token{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		Subject:      subject,
+		ClientID:     googleID,
+		Expiry:       time.Now().Add(time.Hour),
+	}
+}
+
+func testTokenStore(t *testing.T, name string, s TokenStore) {
+	ctx := context.Background()
+	for _, tok := range []*Token{
+		testToken("a1", "r1", "alice"),
+		testToken("a2", "r2", "alice"),
+		testToken("b1", "r3", "bob"),
+	} {
+		if err := s.Save(ctx, tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	refreshed := testToken("a3", "r1", "alice")
+	refreshed.Expiry = refreshed.Expiry.Add(time.Minute)
+	if err := s.Save(ctx, refreshed); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := s.Access(ctx, "b1"); err != nil || got.Subject != "bob" {
+		t.Errorf("%s: access: want: bob, got: %v %v", name, got, err)
+	}
+	if _, err := s.Access(ctx, "unknown"); err != ErrTokenNotFound {
+		t.Errorf("%s: access: want: %v, got: %v", name, ErrTokenNotFound, err)
+	}
+	if got, err := s.Refresh(ctx, "r1"); err != nil || got.AccessToken != "a3" {
+		t.Errorf("%s: refresh: want: a3, got: %v %v", name, got, err)
+	}
+	if _, err := s.Access(ctx, "a1"); err != ErrTokenNotFound {
+		t.Errorf("%s: superseded: want: %v, got: %v", name, ErrTokenNotFound, err)
+	}
+	if got, err := s.User(ctx, "alice"); err != nil || len(got) != 2 {
+		t.Errorf("%s: user: want: 2, got: %v %v", name, len(got), err)
+	}
+
+	if err := s.Revoke(ctx, "r1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, access := range []string{"a1", "a3"} {
+		if _, err := s.Access(ctx, access); err != ErrTokenNotFound {
+			t.Errorf("%s: revoked %s: want: %v, got: %v", name, access, ErrTokenNotFound, err)
+		}
+	}
+	if err := s.Revoke(ctx, "a2"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.User(ctx, "alice"); len(got) != 0 {
+		t.Errorf("%s: user after revoke: want: 0, got: %v", name, len(got))
+	}
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	testTokenStore(t, "memory", NewMemoryTokenStore())
+}
+
+func TestFileTokenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "identity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tokens.json")
+
+	s, err := NewFileTokenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTokenStore(t, "file", s)
+
+	if err := s.Save(context.Background(), testToken("c1", "r4", "carol")); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewFileTokenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Access(context.Background(), "c1"); err != nil || got.Subject != "carol" {
+		t.Errorf("want: carol, got: %v %v", got, err)
+	}
+	if _, err := reopened.Access(context.Background(), "b1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRefreshReplacesToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "identity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, err := NewFileTokenStore(filepath.Join(dir, "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]TokenStore{
+		"memory": NewMemoryTokenStore(),
+		"file":   file,
+	} {
+		provider := newProvider()
+		cfg := testConfig(provider.URL)
+		cfg.Tokens = store
+		h, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		first := &tokenResponse{}
+		decode(t, exchange(h, linkedCode(t, h), googleSecret), first)
+		for i := 0; i < 5; i++ {
+			w := post(h.GrantHandler, url.Values{
+				grantTypeKey:    {refreshTokenGrant},
+				refreshTokenKey: {first.RefreshToken},
+			}, googleSecret)
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: want: %v, got: %v %s", name, http.StatusOK, w.Code, w.Body)
+			}
+		}
+		if got, err := store.User(context.Background(), "user-token-1"); err != nil || len(got) != 1 {
+			t.Errorf("%s: want: 1 token, got: %v %v", name, len(got), err)
+		}
+		provider.Close()
+	}
+}
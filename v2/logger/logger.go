@@ -1,7 +1,110 @@
+// Package logger defines the logging interface used by fulfillment and its adapters.
 package logger
 
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
 // Logger logs to console.
 type Logger interface {
 	Errorf(format string, args ...interface{})
 	Infof(format string, args ...interface{})
 }
+
+// Field is a key and value attached to log lines.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a Field.
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// FieldLogger is a Logger that keeps fields as structured data, such as the slog adapter.
+type FieldLogger interface {
+	Logger
+
+	// With returns a Logger attaching fields to every line.
+	With(fields ...Field) Logger
+}
+
+// With returns a Logger attaching fields to every line logged to l. Loggers that are
+// not FieldLoggers get the fields appended to the message as key=value.
+func With(l Logger, fields ...Field) Logger {
+	if len(fields) == 0 || l == Discard {
+		return l
+	}
+	if fl, ok := l.(FieldLogger); ok {
+		return fl.With(fields...)
+	}
+	return &fieldLogger{l: l, suffix: formatFields(fields)}
+}
+
+func formatFields(fields []Field) string {
+	var b strings.Builder
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %s=%q", f.Key, fmt.Sprint(f.Value))
+	}
+	return b.String()
+}
+
+// fieldLogger appends fields to the messages of a Logger without structured data.
+type fieldLogger struct {
+	l      Logger
+	suffix string
+}
+
+func (l *fieldLogger) Errorf(format string, args ...interface{}) {
+	l.l.Errorf("%s%s", fmt.Sprintf(format, args...), l.suffix)
+}
+
+func (l *fieldLogger) Infof(format string, args ...interface{}) {
+	l.l.Infof("%s%s", fmt.Sprintf(format, args...), l.suffix)
+}
+
+// With implements FieldLogger, so fields accumulate on a single suffix.
+func (l *fieldLogger) With(fields ...Field) Logger {
+	return &fieldLogger{l: l.l, suffix: l.suffix + formatFields(fields)}
+}
+
+// Discard is a Logger that logs nothing.
+var Discard Logger = discard{}
+
+type discard struct{}
+
+func (discard) Errorf(format string, args ...interface{}) {}
+func (discard) Infof(format string, args ...interface{})  {}
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+)
+
+// NewContext returns a copy of ctx carrying l.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the Logger in ctx, or Discard if there is none.
+func FromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(loggerKey).(Logger); ok {
+		return l
+	}
+	return Discard
+}
+
+// Inject puts the Logger fn returns for each request in the request's context, so
+// handlers can log with FromContext. appengine.New is such a fn.
+func Inject(fn func(r *http.Request) Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), fn(r))))
+		})
+	}
+}
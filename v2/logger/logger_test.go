@@ -0,0 +1,30 @@
+package logger_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2/logger"
+	"github.com/damondouglas/go.actions/v2/logger/loggertest"
+)
+
+func TestFromContext(t *testing.T) {
+	if got := logger.FromContext(context.Background()); got != logger.Discard {
+		t.Errorf("want: Discard, got: %v", got)
+	}
+
+	l := &loggertest.Logger{}
+	h := logger.Inject(func(r *http.Request) logger.Logger {
+		return l
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).Infof("handling %s", r.URL.Path)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/action", nil))
+
+	want := loggertest.Entry{Level: loggertest.Info, Message: "handling /action"}
+	if got := l.Entries(); len(got) != 1 || got[0].String() != want.String() {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
@@ -0,0 +1,86 @@
+// Package loggertest captures log entries for tests.
+package loggertest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/damondouglas/go.actions/v2/logger"
+)
+
+// Levels of captured entries.
+const (
+	Info  = "INFO"
+	Error = "ERROR"
+)
+
+// Entry is one captured log line.
+type Entry struct {
+	Level   string
+	Message string
+	Fields  []logger.Field
+}
+
+// String formats e as "LEVEL message".
+func (e Entry) String() string {
+	return e.Level + " " + e.Message
+}
+
+// Field returns the value of the field with key, if any.
+func (e Entry) Field(key string) (interface{}, bool) {
+	for _, f := range e.Fields {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Logger captures entries instead of logging them. The zero value is ready to use.
+type Logger struct {
+	mu      sync.Mutex
+	entries []Entry
+
+	root   *Logger
+	fields []logger.Field
+}
+
+// Infof captures an Info entry.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.add(Info, fmt.Sprintf(format, args...))
+}
+
+// Errorf captures an Error entry.
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.add(Error, fmt.Sprintf(format, args...))
+}
+
+// With implements logger.FieldLogger. Entries of the returned Logger are captured by l.
+func (l *Logger) With(fields ...logger.Field) logger.Logger {
+	return &Logger{
+		root:   l.store(),
+		fields: append(append([]logger.Field(nil), l.fields...), fields...),
+	}
+}
+
+func (l *Logger) store() *Logger {
+	if l.root != nil {
+		return l.root
+	}
+	return l
+}
+
+func (l *Logger) add(level, msg string) {
+	s := l.store()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, Entry{Level: level, Message: msg, Fields: l.fields})
+}
+
+// Entries returns the captured entries in order.
+func (l *Logger) Entries() []Entry {
+	s := l.store()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Entry(nil), s.entries...)
+}
@@ -0,0 +1,41 @@
+// Package slog adapts log/slog to logger.Logger, keeping structured attributes.
+package slog
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/damondouglas/go.actions/v2/logger"
+)
+
+// Logger logs to a *slog.Logger.
+type Logger struct {
+	l *slog.Logger
+}
+
+// New Logger writing to l, or to slog.Default() if l is nil.
+func New(l *slog.Logger) *Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &Logger{l: l}
+}
+
+// With implements logger.FieldLogger, adding fields as attributes to every record.
+func (l *Logger) With(fields ...logger.Field) logger.Logger {
+	args := make([]interface{}, len(fields))
+	for i, f := range fields {
+		args[i] = slog.Any(f.Key, f.Value)
+	}
+	return &Logger{l: l.l.With(args...)}
+}
+
+// Infof log to console.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.l.Info(fmt.Sprintf(format, args...))
+}
+
+// Errorf log to console.
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.l.Error(fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,30 @@
+package slog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2/logger"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(slog.New(slog.NewJSONHandler(&buf, nil))).With(logger.F("session", "abc"))
+	l.Errorf("failed: %d", 42)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{
+		"level":   "ERROR",
+		"msg":     "failed: 42",
+		"session": "abc",
+	} {
+		if got := record[key]; got != want {
+			t.Errorf("%s: want: %v, got: %v", key, want, got)
+		}
+	}
+}
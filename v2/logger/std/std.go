@@ -0,0 +1,33 @@
+// Package std adapts the standard library's log package to logger.Logger.
+package std
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/damondouglas/go.actions/v2/logger"
+)
+
+type loggerBase struct {
+	l *log.Logger
+}
+
+// New Logger writing to l, or to standard error if l is nil. Lines are prefixed with
+// their level.
+func New(l *log.Logger) logger.Logger {
+	if l == nil {
+		l = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return &loggerBase{l: l}
+}
+
+// Infof log to console.
+func (l *loggerBase) Infof(format string, args ...interface{}) {
+	l.l.Output(2, "INFO: "+fmt.Sprintf(format, args...))
+}
+
+// Errorf log to console.
+func (l *loggerBase) Errorf(format string, args ...interface{}) {
+	l.l.Output(2, "ERROR: "+fmt.Sprintf(format, args...))
+}
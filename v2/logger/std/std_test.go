@@ -0,0 +1,19 @@
+package std
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(log.New(&buf, "", 0))
+	l.Infof("hello %s", "world")
+	l.Errorf("failed: %d", 42)
+
+	want := "INFO: hello world\nERROR: failed: 42\n"
+	if got := buf.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
@@ -0,0 +1,215 @@
+// Package redact masks personal data in requests before they are logged or recorded.
+package redact
+
+import (
+	"encoding/json"
+
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/google"
+)
+
+// Redacted replaces masked values.
+const Redacted = "REDACTED"
+
+var redactedJSON = json.RawMessage(`"` + Redacted + `"`)
+
+// locationKeys are the keys of extensions holding locations.
+var locationKeys = []string{"location", "deliveryAddress"}
+
+// Fields selects the personal data to mask.
+type Fields uint
+
+// Fields of a request.
+const (
+	// AccessToken is google.User.AccessToken.
+	AccessToken Fields = 1 << iota
+
+	// IDToken is google.User.IDToken.
+	IDToken
+
+	// Profile is google.User.Profile.
+	Profile
+
+	// Location is google.Device.Location, place arguments and the location and
+	// deliveryAddress keys of argument extensions, such as a TransactionDecisionValue.
+	Location
+
+	// UserID is google.User.UserID.
+	UserID
+
+	// UserStorage is google.User.UserStorage.
+	UserStorage
+
+	// QueryText is what the user said: dialogflow QueryResult.QueryText and raw inputs.
+	QueryText
+
+	// Parameters are the values of dialogflow QueryResult parameters and output context
+	// parameters, which slot filling and helpers fill with names, addresses and phone
+	// numbers. Their names are kept.
+	Parameters
+
+	// DefaultFields are the fields masked by Redact.
+	DefaultFields = AccessToken | IDToken | Profile | Location | Parameters
+)
+
+// Redactor masks Fields.
+type Redactor struct {
+	Fields Fields
+}
+
+// Default masks DefaultFields.
+var Default = &Redactor{Fields: DefaultFields}
+
+// Redact returns a deep copy of req with DefaultFields masked.
+func Redact(req *dialogflow.Request) *dialogflow.Request {
+	return Default.Request(req)
+}
+
+// Request returns a deep copy of req with r's fields masked. If req cannot be copied,
+// e.g. it holds invalid raw JSON, only its response ID and session are kept.
+func (r *Redactor) Request(req *dialogflow.Request) *dialogflow.Request {
+	if req == nil {
+		return nil
+	}
+	c := &dialogflow.Request{}
+	if err := deepCopy(req, c); err != nil {
+		return &dialogflow.Request{ResponseID: req.ResponseID, Session: req.Session}
+	}
+	if r.Fields&Parameters != 0 {
+		for name := range c.QueryResult.RawParameterData {
+			c.QueryResult.RawParameterData[name] = redactedJSON
+		}
+	}
+	for _, ctx := range c.QueryResult.OutputContexts {
+		for name, value := range ctx.Parameters {
+			switch {
+			case r.Fields&Parameters != 0:
+				ctx.Parameters[name] = redactedJSON
+			case r.Fields&Location != 0:
+				ctx.Parameters[name] = maskKeys(value, locationKeys...)
+			}
+		}
+	}
+	c.DecodeParameters()
+	if r.Fields&QueryText != 0 && c.QueryResult.QueryText != "" {
+		c.QueryResult.QueryText = Redacted
+	}
+	r.mask(c.OriginalDetectIntentRequest.Payload)
+	return c
+}
+
+// GoogleRequest returns a deep copy of req with r's fields masked. If req cannot be
+// copied, the copy is empty.
+func (r *Redactor) GoogleRequest(req *google.Request) *google.Request {
+	if req == nil {
+		return nil
+	}
+	c := &google.Request{}
+	if err := deepCopy(req, c); err != nil {
+		return &google.Request{}
+	}
+	r.mask(c)
+	return c
+}
+
+// deepCopy copies src to dst through JSON. Requests are decoded from JSON, so nothing
+// is lost.
+func deepCopy(src, dst interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+func (r *Redactor) mask(req *google.Request) {
+	if req == nil {
+		return
+	}
+	if u := req.User; u != nil {
+		r.maskString(AccessToken, &u.AccessToken)
+		r.maskString(IDToken, &u.IDToken)
+		r.maskString(UserID, &u.UserID)
+		r.maskString(UserStorage, &u.UserStorage)
+		if r.Fields&Profile != 0 && u.Profile != nil {
+			u.Profile = &google.UserProfile{
+				DisplayName: Redacted,
+				GivenName:   Redacted,
+				FamilyName:  Redacted,
+			}
+		}
+	}
+	if r.Fields&Location != 0 && req.Device != nil && req.Device.Location != nil {
+		req.Device.Location = redactedLocation()
+	}
+	for _, in := range req.Inputs {
+		for _, raw := range in.RawInputs {
+			r.maskString(QueryText, &raw.Query)
+		}
+		for _, arg := range in.Arguments {
+			if r.Fields&QueryText != 0 && arg.RawText != "" {
+				arg.RawText = Redacted
+			}
+			if r.Fields&Location == 0 {
+				continue
+			}
+			if arg.PlaceValue != nil {
+				arg.PlaceValue = redactedLocation()
+			}
+			arg.Extension = maskKeys(arg.Extension, locationKeys...)
+		}
+	}
+}
+
+func (r *Redactor) maskString(f Fields, s *string) {
+	if r.Fields&f != 0 && *s != "" {
+		*s = Redacted
+	}
+}
+
+func redactedLocation() *google.Location {
+	return &google.Location{FormattedAddress: Redacted}
+}
+
+// maskKeys masks the values of keys at any depth of the JSON object data.
+func maskKeys(data json.RawMessage, keys ...string) json.RawMessage {
+	if len(data) == 0 {
+		return data
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return data
+	}
+	masked, err := json.Marshal(maskValue(v, keys))
+	if err != nil {
+		return data
+	}
+	return masked
+}
+
+func maskValue(v interface{}, keys []string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if contains(keys, k) {
+				v[k] = Redacted
+				continue
+			}
+			v[k] = maskValue(e, keys)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = maskValue(e, keys)
+		}
+	}
+	return v
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package redact
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/google"
+)
+
+const mockPath = "../mock/v2"
+
+func request(t *testing.T, mock string) *dialogflow.Request {
+	f, err := os.Open(mockPath + "/" + mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	req, err := dialogflow.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func encoded(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRedact(t *testing.T) {
+	req := request(t, "PermissionEvent.json")
+	req.OriginalDetectIntentRequest.Payload.User.AccessToken = "secret-token"
+	before := encoded(t, req)
+
+	got := encoded(t, Redact(req))
+	for _, pii := range []string{"secret-token", "Matt Carroll", "37.4219806"} {
+		if strings.Contains(got, pii) {
+			t.Errorf("want %s redacted, got: %s", pii, got)
+		}
+	}
+	if after := encoded(t, req); after != before {
+		t.Error("want original unchanged")
+	}
+	if !strings.Contains(got, req.OriginalDetectIntentRequest.Payload.User.UserID) {
+		t.Error("want UserID kept by default")
+	}
+}
+
+func TestRedactExtension(t *testing.T) {
+	req := request(t, "DeliveryAddressEvent.json")
+	got := encoded(t, Redact(req))
+	if strings.Contains(got, "AMPHITHEATRE") {
+		t.Errorf("want delivery address redacted, got: %s", got)
+	}
+	if !strings.Contains(got, "ACCEPTED") {
+		t.Errorf("want extension kept, got: %s", got)
+	}
+}
+
+func TestRedactTransactionDecision(t *testing.T) {
+	req := request(t, "request.json")
+	input := req.OriginalDetectIntentRequest.Payload.Inputs[0]
+	input.Arguments = append(input.Arguments, &google.Argument{
+		Name: google.TransactionDecisionArgument,
+		Extension: json.RawMessage(`{
+			"@type": "` + google.TransactionDecisionValueType + `",
+			"userDecision": "ORDER_ACCEPTED",
+			"deliveryAddress": {
+				"postalAddress": {"addressLines": ["1600 AMPHITHEATRE PARKWAY"]},
+				"phoneNumber": "+1 650-253-0000"
+			}
+		}`),
+	})
+	got := encoded(t, Redact(req))
+	for _, pii := range []string{"AMPHITHEATRE", "650-253-0000"} {
+		if strings.Contains(got, pii) {
+			t.Errorf("want %s redacted, got: %s", pii, got)
+		}
+	}
+	if !strings.Contains(got, "ORDER_ACCEPTED") {
+		t.Errorf("want decision kept, got: %s", got)
+	}
+}
+
+func TestRedactParameters(t *testing.T) {
+	req := request(t, "request.json")
+	req.QueryResult.RawParameterData = map[string]json.RawMessage{
+		"phone-number": json.RawMessage(`"+1 650-253-0000"`),
+		"address":      json.RawMessage(`{"street-address": "1600 Amphitheatre Parkway"}`),
+	}
+	req.DecodeParameters()
+
+	c := Redact(req)
+	got := encoded(t, c)
+	for _, pii := range []string{"650-253-0000", "Amphitheatre"} {
+		if strings.Contains(got, pii) {
+			t.Errorf("want %s redacted, got: %s", pii, got)
+		}
+	}
+	for _, name := range []string{"phone-number", "address"} {
+		if _, ok := c.QueryResult.Parameters[name]; !ok {
+			t.Errorf("want parameter %s kept", name)
+		}
+		if got := string(c.QueryResult.RawParameterData[name]); got != `"`+Redacted+`"` {
+			t.Errorf("want: %q, got: %s", Redacted, got)
+		}
+	}
+	if !strings.Contains(string(req.QueryResult.RawParameterData["phone-number"]), "650") {
+		t.Error("want original unchanged")
+	}
+	kept := encoded(t, (&Redactor{Fields: AccessToken}).Request(req))
+	if !strings.Contains(kept, "Amphitheatre") {
+		t.Errorf("want parameters kept without Parameters, got: %s", kept)
+	}
+}
+
+func TestRedactorFields(t *testing.T) {
+	req := request(t, "request.json")
+	r := &Redactor{Fields: QueryText | UserID}
+	c := r.Request(req)
+	if c.QueryResult.QueryText != Redacted {
+		t.Errorf("want: %v, got: %v", Redacted, c.QueryResult.QueryText)
+	}
+	payload := c.OriginalDetectIntentRequest.Payload
+	if payload.User.UserID != Redacted {
+		t.Errorf("want: %v, got: %v", Redacted, payload.User.UserID)
+	}
+	if got := payload.Inputs[0].RawInputs[0].Query; got != Redacted {
+		t.Errorf("want: %v, got: %v", Redacted, got)
+	}
+	if got := r.GoogleRequest(req.OriginalDetectIntentRequest.Payload).User.UserID; got != Redacted {
+		t.Errorf("want: %v, got: %v", Redacted, got)
+	}
+	if req.QueryResult.QueryText == Redacted {
+		t.Error("want original unchanged")
+	}
+}
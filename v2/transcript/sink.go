@@ -0,0 +1,107 @@
+package transcript
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// WriterSink writes entries as JSON Lines to an io.Writer.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a WriterSink writing to w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Write implements Sink.
+func (s *WriterSink) Write(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+// FileSink appends entries as JSON Lines to one file per session in Dir, named after
+// the session ID with a .jsonl extension.
+type FileSink struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+// Write implements Sink.
+func (s *FileSink) Write(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path(e.Session), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Path returns the file of session.
+func (s *FileSink) Path(session string) string {
+	return filepath.Join(s.Dir, url.PathEscape(path.Base(session))+".jsonl")
+}
+
+// Memory keeps entries in memory, by session.
+type Memory struct {
+	mu       sync.Mutex
+	sessions map[string][]*Entry
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{
+		sessions: map[string][]*Entry{},
+	}
+}
+
+// Write implements Sink.
+func (m *Memory) Write(e *Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[e.Session] = append(m.sessions[e.Session], e)
+	return nil
+}
+
+// Session returns the entries of session in order.
+func (m *Memory) Session(session string) []*Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Entry(nil), m.sessions[session]...)
+}
+
+// Sessions returns the recorded sessions, sorted.
+func (m *Memory) Sessions() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sessions := make([]string, 0, len(m.sessions))
+	for s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	sort.Strings(sessions)
+	return sessions
+}
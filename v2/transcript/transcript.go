@@ -0,0 +1,78 @@
+// Package transcript records fulfillment conversations as JSON Lines, one entry per turn,
+// for debugging them after the fact.
+package transcript
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/fulfillment"
+	"github.com/damondouglas/go.actions/v2/logger"
+	"github.com/damondouglas/go.actions/v2/redact"
+)
+
+// Entry is one turn of a conversation.
+type Entry struct {
+	Time       time.Time       `json:"time"`
+	Session    string          `json:"session"`
+	ResponseID string          `json:"responseId"`
+	Request    json.RawMessage `json:"request"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// Sink stores entries.
+type Sink interface {
+	Write(e *Entry) error
+}
+
+// Record records each request, redacted by redact.Redact and encoded as Dialogflow sends
+// it, and its encoded response, or the handler's error, to s.
+// Failures to record are logged to the context's logger and do not fail the request.
+func Record(s Sink) fulfillment.Middleware {
+	return func(next fulfillment.Handler) fulfillment.Handler {
+		encoded := fulfillment.AfterEncode(func(ctx context.Context, req *dialogflow.Request, data []byte, err error) {
+			write(ctx, s, req, data, err)
+		})(next)
+		return fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+			enc, err := encoded.Fulfill(ctx, req)
+			if err != nil {
+				write(ctx, s, req, nil, err)
+			}
+			return enc, err
+		})
+	}
+}
+
+func write(ctx context.Context, s Sink, req *dialogflow.Request, data []byte, err error) {
+	e, rerr := newEntry(req, data, err)
+	if rerr == nil {
+		rerr = s.Write(e)
+	}
+	if rerr != nil {
+		logger.FromContext(ctx).Errorf("transcript: recording %s: %v", req.ResponseID, rerr)
+	}
+}
+
+func newEntry(req *dialogflow.Request, data []byte, err error) (*Entry, error) {
+	request, rerr := json.Marshal(redact.Redact(req))
+	if rerr != nil {
+		return nil, rerr
+	}
+	e := &Entry{
+		Time:       time.Now(),
+		Session:    req.Session,
+		ResponseID: req.ResponseID,
+		Request:    request,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	} else if len(data) > 0 {
+		e.Response = json.RawMessage(strings.TrimSpace(string(data)))
+	}
+	return e, nil
+}
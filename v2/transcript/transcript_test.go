@@ -0,0 +1,158 @@
+package transcript
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/damondouglas/go.actions/v2"
+	"github.com/damondouglas/go.actions/v2/dialogflow"
+	"github.com/damondouglas/go.actions/v2/fulfillment"
+	"github.com/damondouglas/go.actions/v2/redact"
+)
+
+const (
+	mockPath = "../mock/v2"
+	session  = "projects/integrationfulfillmenttest/agent/sessions/1522951193000"
+)
+
+func serve(t *testing.T, h http.Handler, mock string) {
+	f, err := os.Open(mockPath + "/" + mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", f))
+}
+
+func router(s Sink) *fulfillment.Router {
+	r := fulfillment.NewRouter()
+	r.Use(Record(s))
+	r.Fallback(fulfillment.HandlerFunc(func(ctx context.Context, req *dialogflow.Request) (v2.Encoder, error) {
+		if req.QueryResult.QueryText == "fail" {
+			return nil, errors.New("boom")
+		}
+		return &v2.Simple{Say: "hello"}, nil
+	}))
+	return r
+}
+
+func TestRecord(t *testing.T) {
+	m := NewMemory()
+	serve(t, router(m), "PermissionEvent.json")
+
+	sessions := m.Sessions()
+	if len(sessions) != 1 {
+		t.Fatalf("want: 1 session, got: %v", sessions)
+	}
+	entries := m.Session(sessions[0])
+	if len(entries) != 1 {
+		t.Fatalf("want: 1 entry, got: %v", len(entries))
+	}
+	e := entries[0]
+	if !bytes.Contains(e.Response, []byte(`"textToSpeech":"hello"`)) {
+		t.Errorf("want response, got: %s", e.Response)
+	}
+	for _, pii := range []string{"Matt Carroll", "37.4219806"} {
+		if bytes.Contains(e.Request, []byte(pii)) {
+			t.Errorf("want %s redacted, got: %s", pii, e.Request)
+		}
+	}
+	if !bytes.Contains(e.Request, []byte(redact.Redacted)) {
+		t.Errorf("want redacted fields, got: %s", e.Request)
+	}
+}
+
+func TestRecordWireFormat(t *testing.T) {
+	m := NewMemory()
+	serve(t, router(m), "DeliveryAddressEvent.json")
+	e := m.Session(m.Sessions()[0])[0]
+
+	for _, bad := range []string{`"QueryResult"`, `"Parameters"`, `"DatetimeValue"`, `null`} {
+		if bytes.Contains(e.Request, []byte(bad)) {
+			t.Errorf("want no %s, got: %s", bad, e.Request)
+		}
+	}
+	var req struct {
+		QueryResult struct {
+			OutputContexts []struct {
+				Parameters map[string]json.RawMessage
+			} `json:"outputContexts"`
+		} `json:"queryResult"`
+	}
+	if err := json.Unmarshal(e.Request, &req); err != nil {
+		t.Fatal(err)
+	}
+	contexts := req.QueryResult.OutputContexts
+	if len(contexts) != 1 || len(contexts[0].Parameters) == 0 {
+		t.Fatalf("want output context parameters, got: %s", e.Request)
+	}
+	if bytes.Contains(e.Request, []byte("AMPHITHEATRE")) {
+		t.Errorf("want context parameters redacted, got: %s", e.Request)
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	m := NewMemory()
+	h := router(m)
+	req := &dialogflow.Request{Session: session}
+	req.QueryResult.QueryText = "fail"
+	if _, err := h.Fulfill(context.Background(), req); err == nil {
+		t.Fatal("want error")
+	}
+	entries := m.Session(session)
+	if len(entries) != 1 || entries[0].Error != "boom" {
+		t.Errorf("want error recorded, got: %v", entries)
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	h := router(NewWriterSink(&buf))
+	serve(t, h, "request.json")
+	serve(t, h, "request.json")
+
+	lines := 0
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		e := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Session != session {
+			t.Errorf("want: %v, got: %v", session, e.Session)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("want: 2 lines, got: %v", lines)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transcript")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &FileSink{Dir: dir}
+	serve(t, router(s), "request.json")
+	serve(t, router(s), "request.json")
+
+	data, err := ioutil.ReadFile(s.Path(session))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(data), "\n"); got != 2 {
+		t.Errorf("want: 2 lines, got: %v", got)
+	}
+}